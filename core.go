@@ -9,6 +9,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -19,13 +21,26 @@ import (
 type Api struct {
 	Env                    string
 	ApiUrlAddMerchant      string
+	ApiUrlModifyMerchant   string // 合作推廣商 API 技術串接手冊「修改合作商店資料」, 同建立商店網址加上 /modify
+	ApiUrlQueryMerchant    string // 查詢合作商店, 未列於公開的合作推廣商手冊, 須向藍新取得網址後自行設定
+	ApiUrlChargeInstruct   string
 	ApiUrlMPGTransaction   string
 	ApiUrlTransaction      string
 	ApiUrlCreditCardCancel string
 	ApiUrlCreditCardClose  string
 	ApiUrlInvoiceIssue     string
 	ApiUrlInvoiceMemo      string
+	ApiUrlInvoiceTouch     string
+	ApiUrlInvoiceInvalid   string
+	ApiUrlInvoiceSearch    string
+	ApiUrlAllowanceTouch   string
+	ApiUrlAllowanceInvalid string
+	ApiUrlCheckBarCode     string
+	ApiUrlCheckLoveCode    string
+	ApiUrlInvoiceNumber    string
 	ApiUrlQueryTradeInfo   string
+
+	StrictCheckCode bool // CheckCode 不符或未回傳時回傳錯誤; 預設 false 僅記錄, 不符的回應仍視為成功
 }
 
 func New(env string) *Api {
@@ -34,24 +49,44 @@ func New(env string) *Api {
 		return &Api{
 			Env:                    env,
 			ApiUrlAddMerchant:      "https://core.newebpay.com/API/AddMerchant",
+			ApiUrlModifyMerchant:   "https://core.newebpay.com/API/AddMerchant/modify",
+			ApiUrlChargeInstruct:   "https://core.newebpay.com/API/ChargeInstruct",
 			ApiUrlMPGTransaction:   "https://core.newebpay.com/MPG/mpg_gateway",
 			ApiUrlTransaction:      "https://core.newebpay.com/API/CreditCard",
 			ApiUrlCreditCardCancel: "https://core.newebpay.com/API/CreditCard/Cancel",
 			ApiUrlCreditCardClose:  "https://core.newebpay.com/API/CreditCard/Close",
 			ApiUrlInvoiceIssue:     "https://inv.ezpay.com.tw/Api/invoice_issue",
 			ApiUrlInvoiceMemo:      "https://inv.ezpay.com.tw/Api/allowance_issue",
+			ApiUrlInvoiceTouch:     "https://inv.ezpay.com.tw/Api/invoice_touch_issue",
+			ApiUrlInvoiceInvalid:   "https://inv.ezpay.com.tw/Api/invoice_invalid",
+			ApiUrlInvoiceSearch:    "https://inv.ezpay.com.tw/Api/invoice_search",
+			ApiUrlAllowanceTouch:   "https://inv.ezpay.com.tw/Api/allowance_touch_issue",
+			ApiUrlAllowanceInvalid: "https://inv.ezpay.com.tw/Api/allowanceInvalid",
+			ApiUrlCheckBarCode:     "https://inv.ezpay.com.tw/Api_inv_application/checkBarCode",
+			ApiUrlCheckLoveCode:    "https://inv.ezpay.com.tw/Api_inv_application/checkLoveCode",
+			ApiUrlInvoiceNumber:    "https://inv.ezpay.com.tw/Api_number_management/searchNumber",
 			ApiUrlQueryTradeInfo:   "https://core.newebpay.com/API/QueryTradeInfo",
 		}
 	default:
 		return &Api{
 			Env:                    env,
 			ApiUrlAddMerchant:      "https://ccore.newebpay.com/API/AddMerchant",
+			ApiUrlModifyMerchant:   "https://ccore.newebpay.com/API/AddMerchant/modify",
+			ApiUrlChargeInstruct:   "https://ccore.newebpay.com/API/ChargeInstruct",
 			ApiUrlMPGTransaction:   "https://ccore.newebpay.com/MPG/mpg_gateway",
 			ApiUrlTransaction:      "https://ccore.newebpay.com/API/CreditCard",
 			ApiUrlCreditCardCancel: "https://ccore.newebpay.com/API/CreditCard/Cancel",
 			ApiUrlCreditCardClose:  "https://ccore.newebpay.com/API/CreditCard/Close",
 			ApiUrlInvoiceIssue:     "https://cinv.ezpay.com.tw/Api/invoice_issue",
 			ApiUrlInvoiceMemo:      "https://cinv.ezpay.com.tw/Api/allowance_issue",
+			ApiUrlInvoiceTouch:     "https://cinv.ezpay.com.tw/Api/invoice_touch_issue",
+			ApiUrlInvoiceInvalid:   "https://cinv.ezpay.com.tw/Api/invoice_invalid",
+			ApiUrlInvoiceSearch:    "https://cinv.ezpay.com.tw/Api/invoice_search",
+			ApiUrlAllowanceTouch:   "https://cinv.ezpay.com.tw/Api/allowance_touch_issue",
+			ApiUrlAllowanceInvalid: "https://cinv.ezpay.com.tw/Api/allowanceInvalid",
+			ApiUrlCheckBarCode:     "https://cinv.ezpay.com.tw/Api_inv_application/checkBarCode",
+			ApiUrlCheckLoveCode:    "https://cinv.ezpay.com.tw/Api_inv_application/checkLoveCode",
+			ApiUrlInvoiceNumber:    "https://cinv.ezpay.com.tw/Api_number_management/searchNumber",
 			ApiUrlQueryTradeInfo:   "https://ccore.newebpay.com/API/QueryTradeInfo",
 		}
 	}
@@ -84,8 +119,12 @@ func httpBuildQuery(data interface{}) (string, error) {
 		return "", err
 	}
 
+	// 以 json.Number 保留數字原樣: 解碼為 float64 時 1000000 以上的整數會被 %v 格式化為 1e+06,
+	// 加密後的 PostData 金額錯誤且 CheckValue 與藍新計算的不符
 	var result map[string]interface{}
-	if err := json.Unmarshal(jsonData, &result); err != nil {
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
 		return "", err
 	}
 
@@ -131,6 +170,45 @@ func genCheckCode(amount int, merchantId, merchantOrderNo, tradeNo, hashKey, has
 	return strings.ToUpper(hex.EncodeToString(hash[:])), nil
 }
 
+var ErrCheckCodeMismatch = errors.New("check code mismatch")
+var ErrCheckCodeMissing = errors.New("check code missing")
+
+// 驗證藍新金流回傳的 CheckCode, 可用於 NotifyURL 等非 API 呼叫取得的結果
+func VerifyCheckCode(checkCode string, amount int, merchantId, merchantOrderNo, tradeNo, hashKey, hashIv string) error {
+	if checkCode == "" {
+		return fmt.Errorf("%w: merchantOrderNo: %s, tradeNo: %s", ErrCheckCodeMissing, merchantOrderNo, tradeNo)
+	}
+
+	expected, err := genCheckCode(amount, merchantId, merchantOrderNo, tradeNo, hashKey, hashIv)
+	if err != nil {
+		return err
+	}
+
+	if expected != checkCode {
+		return fmt.Errorf("%w: merchantOrderNo: %s, tradeNo: %s", ErrCheckCodeMismatch, merchantOrderNo, tradeNo)
+	}
+
+	return nil
+}
+
+// 檢核碼不符或未回傳時, StrictCheckCode 為 true 才回傳錯誤; 否則僅記錄並視為成功
+func (a Api) verifyCheckCode(ok bool, err error) error {
+	if err == nil && ok {
+		return nil
+	}
+
+	if err == nil {
+		err = ErrCheckCodeMismatch
+	}
+
+	if a.StrictCheckCode {
+		return err
+	}
+
+	fmt.Printf("檢核碼驗證失敗: %v\n", err)
+	return nil
+}
+
 func decryptData(encryptedData, hashKey, hashIv string, result interface{}) error {
 	ciphertext, err := hex.DecodeString(encryptedData)
 	if err != nil {
@@ -196,6 +274,58 @@ func NewMerchant(merchantId, hashKey, hashIv string) *Merchant {
 	}
 }
 
+// 以 MerchantID_/PostData_ 加密表單呼叫 ezPay 電子發票 API, Status 非 SUCCESS 時回傳錯誤
+func postInvoiceForm(apiUrl string, merchant *Merchant, postData any, tag string) (*RespPayload, error) {
+	encData, err := encryptData(postData, merchant.HashKey, merchant.HashIv)
+	if err != nil {
+		return nil, fmt.Errorf("Encryption failed: %v", err)
+	}
+
+	formData := url.Values{
+		"MerchantID_": {merchant.MerchantId},
+		"PostData_":   {encData},
+	}
+	fmt.Printf("[%s] url: %s, decrypt post data: %+v, encrypt post data: %s\n", tag, apiUrl, postData, formData)
+
+	resp, err := http.PostForm(apiUrl, formData)
+	fmt.Printf("[%s] 回傳, url: %s, resp: %v, err: %v\n", tag, apiUrl, resp, err)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to submit form: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	receivedData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read received data failed: %v", err)
+	}
+
+	var tp RespPayload
+	if err := json.Unmarshal(receivedData, &tp); err != nil {
+		return nil, fmt.Errorf("[%s] failed to decode response: %v, received data: %s", tag, err, string(receivedData))
+	}
+	fmt.Printf("[%s] 請求結果, url: %s, response: %v\n", tag, apiUrl, tp)
+	if !tp.IsSuccess() {
+		return nil, &InvoiceError{Tag: tag, Status: tp.Status, Message: tp.Message}
+	}
+
+	return &tp, nil
+}
+
+// ezPay 回傳 Status 非 SUCCESS, 例如查無發票、發票已作廢
+type InvoiceError struct {
+	Tag     string
+	Status  string
+	Message string
+}
+
+func (e *InvoiceError) Error() string {
+	return fmt.Sprintf("[%s] %s: %s", e.Tag, e.Status, e.Message)
+}
+
 type RespPayload struct {
 	Status  string `json:"Status"`
 	Message string `json:"Message"`
@@ -219,6 +349,14 @@ func (r RespPayload) AssertString(result any) error {
 	return nil
 }
 
+// ezPay 的 Result 可能為 JSON 字串或物件
+func (r RespPayload) assertAny(result any) error {
+	if _, ok := r.Result.(string); ok {
+		return r.AssertString(result)
+	}
+	return r.Assert(result)
+}
+
 func (r RespPayload) Assert(result any) error {
 	jsonData, err := json.Marshal(r.Result)
 	if err != nil {
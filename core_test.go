@@ -0,0 +1,149 @@
+package newebpay
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/Loopmaas/xtime"
+)
+
+func TestHttpBuildQueryNumbers(t *testing.T) {
+	query, err := httpBuildQuery(struct {
+		Amt         int     `json:"Amt"`
+		CreditLimit int64   `json:"CreditLimit"`
+		Rate        float64 `json:"Rate"`
+		Name        string  `json:"Name"`
+	}{Amt: 1000000, CreditLimit: 12345678901, Rate: 2.5, Name: "a b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"Amt": "1000000", "CreditLimit": "12345678901", "Rate": "2.5", "Name": "a b"}
+	for key, value := range want {
+		if got := values.Get(key); got != value {
+			t.Errorf("%s = %s, want %s", key, got, value)
+		}
+	}
+}
+
+func TestVerifyCheckCode(t *testing.T) {
+	checkCode, err := genCheckCode(1200, "MS123", "ORDER001", "23092714215835071", testMerchant.HashKey, testMerchant.HashIv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		checkCode string
+		amount    int
+		want      error
+	}{
+		{"相符", checkCode, 1200, nil},
+		{"金額不符", checkCode, 1000, ErrCheckCodeMismatch},
+		{"未回傳", "", 1200, ErrCheckCodeMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyCheckCode(tt.checkCode, tt.amount, "MS123", "ORDER001", "23092714215835071", testMerchant.HashKey, testMerchant.HashIv)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("VerifyCheckCode() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApiVerifyCheckCodeStrict(t *testing.T) {
+	tests := []struct {
+		name   string
+		ok     bool
+		err    error
+		strict error
+	}{
+		{"相符", true, nil, nil},
+		{"不符", false, nil, ErrCheckCodeMismatch},
+		{"未回傳", false, ErrCheckCodeMissing, ErrCheckCodeMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (Api{StrictCheckCode: true}).verifyCheckCode(tt.ok, tt.err); !errors.Is(err, tt.strict) || (tt.strict == nil && err != nil) {
+				t.Errorf("strict verifyCheckCode() = %v, want %v", err, tt.strict)
+			}
+			if err := (Api{}).verifyCheckCode(tt.ok, tt.err); err != nil {
+				t.Errorf("lenient verifyCheckCode() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+// 各 API 依 StrictCheckCode 處理回應中相符、不符與未回傳的 CheckCode
+func TestCheckCodeVerificationPaths(t *testing.T) {
+	checkCode, err := genCheckCode(1200, testMerchant.MerchantId, "ORDER001", "23092714215835071", testMerchant.HashKey, testMerchant.HashIv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []struct {
+		name string
+		call func(a Api, url string) error
+	}{
+		{"query", func(a Api, url string) error {
+			a.ApiUrlQueryTradeInfo = url
+			_, err := a.QueryTradeInfo(testMerchant, "ORDER001", 1200, xtime.NowUTC())
+			return err
+		}},
+		{"close", func(a Api, url string) error {
+			a.ApiUrlCreditCardClose = url
+			_, err := a.CreditCardRefundRequest(testMerchant, "ORDER001", 1200, xtime.NowUTC())
+			return err
+		}},
+		{"cancel", func(a Api, url string) error {
+			a.ApiUrlCreditCardCancel = url
+			_, err := a.CreditCardCancelTransactionAuthorization(testMerchant, "ORDER001", 1200, xtime.NowUTC())
+			return err
+		}},
+		{"transaction", func(a Api, url string) error {
+			a.ApiUrlTransaction = url
+			_, err := a.CreditCardTransaction(testMerchant, "user@example.com", "ORDER001", "租金", "term", "token", 1200, xtime.NowUTC())
+			return err
+		}},
+	}
+	checkCodes := []struct {
+		name      string
+		checkCode any
+		strict    error
+	}{
+		{"相符", checkCode, nil},
+		{"不符", "0000", ErrCheckCodeMismatch},
+		{"未回傳", nil, ErrCheckCodeMissing},
+	}
+
+	for _, path := range paths {
+		for _, cc := range checkCodes {
+			t.Run(path.name+"/"+cc.name, func(t *testing.T) {
+				result := map[string]any{
+					"MerchantID":      testMerchant.MerchantId,
+					"Amt":             1200,
+					"TradeNo":         "23092714215835071",
+					"MerchantOrderNo": "ORDER001",
+				}
+				if cc.checkCode != nil {
+					result["CheckCode"] = cc.checkCode
+				}
+				srv := newFakeNewebPay(t, result)
+
+				if err := path.call(Api{StrictCheckCode: true}, srv.URL); !errors.Is(err, cc.strict) || (cc.strict == nil && err != nil) {
+					t.Errorf("strict err = %v, want %v", err, cc.strict)
+				}
+				if err := path.call(Api{}, srv.URL); err != nil {
+					t.Errorf("lenient err = %v, want nil", err)
+				}
+			})
+		}
+	}
+}
@@ -85,6 +85,10 @@ func (a Api) CreditCardCancelTransactionAuthorization(merchant *Merchant, mercha
 		return nil, fmt.Errorf("[cancel] assert: %v", err)
 	}
 
+	if err := a.verifyCheckCode(payload.Result.VerifyCheckCode(merchant.HashKey, merchant.HashIv)); err != nil {
+		return nil, fmt.Errorf("[cancel] %w", err)
+	}
+
 	return &payload, nil
 }
 
@@ -105,3 +109,17 @@ type ResultCreditCardBehavior struct {
 func (r RespCreditCardBehavior) IsSuccess() bool {
 	return r.Status == "SUCCESS"
 }
+
+// 未回傳 CheckCode 時回傳 ErrCheckCodeMissing, 是否視為通過由 Api.StrictCheckCode 決定
+func (r ResultCreditCardBehavior) VerifyCheckCode(hashKey, hashIv string) (bool, error) {
+	if r.CheckCode == nil {
+		return false, ErrCheckCodeMissing
+	}
+
+	checkCode, err := genCheckCode(r.Amt, r.MerchantID, r.MerchantOrderNo, r.TradeNo, hashKey, hashIv)
+	if err != nil {
+		return false, err
+	}
+
+	return (checkCode == *r.CheckCode), nil
+}
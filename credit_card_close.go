@@ -94,14 +94,14 @@ func (a Api) creditCardClose(m *Merchant, requestType, merchantOrderNo string, a
 
 	resp, err := http.PostForm(a.ApiUrlCreditCardClose, formData)
 
-	fmt.Printf("取消信用卡退款請求結果, transactionId: %s, post url: %s, decrypt post data: %v, encrypt post data: %s, resp:%s, err: %s\n", merchantOrderNo, a.ApiUrlCreditCardClose, data, formData, resp, err)
+	fmt.Printf("取消信用卡退款請求結果, transactionId: %s, post url: %s, decrypt post data: %v, encrypt post data: %s, resp: %v, err: %v\n", merchantOrderNo, a.ApiUrlCreditCardClose, data, formData, resp, err)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to submit form: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("取消信用卡退款失敗, transactionId: %s, post url: %s, decrypt post data: %v, encrypt post data: %s, resp: %s\n", merchantOrderNo, a.ApiUrlCreditCardClose, data, formData, resp)
+		fmt.Printf("取消信用卡退款失敗, transactionId: %s, post url: %s, decrypt post data: %v, encrypt post data: %s, resp: %v\n", merchantOrderNo, a.ApiUrlCreditCardClose, data, formData, resp)
 		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
@@ -127,5 +127,9 @@ func (a Api) creditCardClose(m *Merchant, requestType, merchantOrderNo string, a
 		return nil, fmt.Errorf("[close] assert: %v", err)
 	}
 
+	if err := a.verifyCheckCode(payload.Result.VerifyCheckCode(m.HashKey, m.HashIv)); err != nil {
+		return nil, fmt.Errorf("[close] %w", err)
+	}
+
 	return &payload, nil
 }
@@ -7,7 +7,9 @@ require (
 	github.com/Loopmaas/xtime v0.0.2
 	github.com/Loopmaas/xuuid v0.0.2
 	github.com/gin-gonic/gin v1.10.0
+	github.com/go-pdf/fpdf v0.9.0
 	github.com/mitchellh/mapstructure v1.5.0
+	rsc.io/qr v0.2.0
 )
 
 require (
@@ -0,0 +1,153 @@
+package newebpay
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 信用卡分期期數
+type InstallmentTerm int
+
+const (
+	InstallmentNone InstallmentTerm = 0
+	Installment3    InstallmentTerm = 3
+	Installment6    InstallmentTerm = 6
+	Installment12   InstallmentTerm = 12
+	Installment18   InstallmentTerm = 18
+	Installment24   InstallmentTerm = 24
+	Installment30   InstallmentTerm = 30
+)
+
+var InstallmentTerms = []InstallmentTerm{
+	Installment3,
+	Installment6,
+	Installment12,
+	Installment18,
+	Installment24,
+	Installment30,
+}
+
+func (t InstallmentTerm) IsValid() bool {
+	return t == InstallmentNone || slices.Contains(InstallmentTerms, t)
+}
+
+func (t InstallmentTerm) String() string {
+	return strconv.Itoa(int(t))
+}
+
+// InstFlag 欄位: 0=不開, 3,6,12=指定期數 ("," 分隔)
+func installmentFlag(terms []InstallmentTerm) string {
+	flags := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if term == InstallmentNone {
+			continue
+		}
+		flags = append(flags, term.String())
+	}
+
+	if len(flags) == 0 {
+		return "0"
+	}
+
+	return strings.Join(flags, ",")
+}
+
+// 商店於藍新金流啟用的分期期數及各期數的最低交易金額
+type InstallmentPolicy struct {
+	Terms     []InstallmentTerm       // 商店已啟用的分期期數
+	MinAmount map[InstallmentTerm]int // 各期數的最低訂單金額, 未設定時僅檢查每期至少 1 元
+}
+
+func (p InstallmentPolicy) Validate(amount int, terms ...InstallmentTerm) error {
+	for _, term := range terms {
+		if term == InstallmentNone {
+			continue
+		}
+
+		if !term.IsValid() {
+			return fmt.Errorf("[installment] invalid term: %d", term)
+		}
+
+		if !slices.Contains(p.Terms, term) {
+			return fmt.Errorf("[installment] term not enabled: %d", term)
+		}
+
+		if amount < int(term) {
+			return fmt.Errorf("[installment] amount %d is less than term %d", amount, term)
+		}
+
+		if minAmount, ok := p.MinAmount[term]; ok && amount < minAmount {
+			return fmt.Errorf("[installment] amount %d is less than minimum %d of term %d", amount, minAmount, term)
+		}
+	}
+
+	return nil
+}
+
+// 信用卡分期付款明細: 首期金額 InstFirst, 其餘每期金額 InstEach
+type InstallmentSchedule struct {
+	Term  InstallmentTerm `json:"Term"`  // 分期期數
+	First int             `json:"First"` // 首期金額
+	Each  int             `json:"Each"`  // 每期金額
+}
+
+func newInstallmentSchedule(inst, instFirst, instEach int) *InstallmentSchedule {
+	if inst <= 0 {
+		return nil
+	}
+
+	return &InstallmentSchedule{
+		Term:  InstallmentTerm(inst),
+		First: instFirst,
+		Each:  instEach,
+	}
+}
+
+func parseInstallmentSchedule(inst, instFirst, instEach string) (*InstallmentSchedule, error) {
+	if inst == "" || inst == "0" {
+		return nil, nil
+	}
+
+	term, err := strconv.Atoi(inst)
+	if err != nil {
+		return nil, fmt.Errorf("[installment] invalid Inst: %s", inst)
+	}
+
+	first, err := strconv.Atoi(instFirst)
+	if err != nil {
+		return nil, fmt.Errorf("[installment] invalid InstFirst: %s", instFirst)
+	}
+
+	each, err := strconv.Atoi(instEach)
+	if err != nil {
+		return nil, fmt.Errorf("[installment] invalid InstEach: %s", instEach)
+	}
+
+	return newInstallmentSchedule(term, first, each), nil
+}
+
+// 各期應付金額, 第一筆為首期金額; 期數不合法時回傳錯誤
+func (s InstallmentSchedule) Payments() ([]int, error) {
+	if s.Term <= 0 {
+		return nil, fmt.Errorf("[installment] invalid term: %d", s.Term)
+	}
+
+	payments := make([]int, int(s.Term))
+	for i := range payments {
+		if i == 0 {
+			payments[i] = s.First
+		} else {
+			payments[i] = s.Each
+		}
+	}
+	return payments, nil
+}
+
+func (s InstallmentSchedule) Total() int {
+	if s.Term <= 0 {
+		return 0
+	}
+	return s.First + s.Each*(int(s.Term)-1)
+}
@@ -0,0 +1,92 @@
+package newebpay
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestInstallmentSchedulePayments(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule InstallmentSchedule
+		want     []int
+		total    int
+		wantErr  bool
+	}{
+		{"three terms", InstallmentSchedule{Term: Installment3, First: 334, Each: 333}, []int{334, 333, 333}, 1000, false},
+		{"single term", InstallmentSchedule{Term: 1, First: 100, Each: 0}, []int{100}, 100, false},
+		{"zero term", InstallmentSchedule{Term: InstallmentNone}, nil, 0, true},
+		{"negative term", InstallmentSchedule{Term: -3, First: 100, Each: 100}, nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.schedule.Payments()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Payments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Payments() = %v, want %v", got, tt.want)
+			}
+			if total := tt.schedule.Total(); total != tt.total {
+				t.Errorf("Total() = %d, want %d", total, tt.total)
+			}
+		})
+	}
+}
+
+func TestParseInstallmentSchedule(t *testing.T) {
+	s, err := parseInstallmentSchedule("6", "170", "166")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Term != Installment6 || s.Total() != 1000 {
+		t.Errorf("parseInstallmentSchedule() = %+v, total %d", s, s.Total())
+	}
+
+	if s, err := parseInstallmentSchedule("0", "", ""); s != nil || err != nil {
+		t.Errorf("parseInstallmentSchedule(0) = %v, %v, want nil, nil", s, err)
+	}
+
+	if _, err := parseInstallmentSchedule("x", "1", "1"); err == nil {
+		t.Error("parseInstallmentSchedule(x) expected error")
+	}
+}
+
+func TestInstallmentPolicyValidate(t *testing.T) {
+	policy := InstallmentPolicy{
+		Terms:     []InstallmentTerm{Installment3, Installment6},
+		MinAmount: map[InstallmentTerm]int{Installment6: 3000},
+	}
+
+	tests := []struct {
+		name    string
+		amount  int
+		terms   []InstallmentTerm
+		wantErr bool
+	}{
+		{"enabled term", 1000, []InstallmentTerm{Installment3}, false},
+		{"no installment", 1, []InstallmentTerm{InstallmentNone}, false},
+		{"term not enabled", 10000, []InstallmentTerm{Installment12}, true},
+		{"invalid term", 10000, []InstallmentTerm{5}, true},
+		{"below minimum", 2000, []InstallmentTerm{Installment6}, true},
+		{"less than term", 2, []InstallmentTerm{Installment3}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := policy.Validate(tt.amount, tt.terms...); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInstallmentFlag(t *testing.T) {
+	if got := installmentFlag(nil); got != "0" {
+		t.Errorf("installmentFlag(nil) = %s", got)
+	}
+	if got := installmentFlag([]InstallmentTerm{Installment3, InstallmentNone, Installment12}); got != "3,12" {
+		t.Errorf("installmentFlag() = %s", got)
+	}
+}
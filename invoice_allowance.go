@@ -0,0 +1,139 @@
+package newebpay
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+// 開立折讓時的 Status 參數
+const (
+	AllowanceIssuePending   = "0" // 不立即確認折讓
+	AllowanceIssueConfirmed = "1" // 立即確認折讓
+)
+
+// 折讓狀態
+type AllowanceStatus string
+
+const (
+	AllowanceStatusPending   AllowanceStatus = "pending"   // 待確認
+	AllowanceStatusConfirmed AllowanceStatus = "confirmed" // 已確認
+	AllowanceStatusCancelled AllowanceStatus = "cancelled" // 已取消 (未確認前取消)
+	AllowanceStatusVoided    AllowanceStatus = "voided"    // 已作廢 (確認後作廢)
+)
+
+// 以 AllowanceNo 識別的折讓單
+type Allowance struct {
+	AllowanceNo     string          `json:"AllowanceNo"`     // 折讓號
+	InvoiceNumber   string          `json:"InvoiceNumber"`   // 發票號碼
+	MerchantOrderNo string          `json:"MerchantOrderNo"` // 商店訂單編號
+	AllowanceAmt    int             `json:"AllowanceAmt"`    // 折讓金額 (含稅)
+	RemainAmt       int             `json:"RemainAmt"`       // 發票剩餘可折讓金額
+	Status          AllowanceStatus `json:"Status"`
+}
+
+type TouchAllowancePostData struct {
+	RespondType     string `json:"RespondType"`     // 回應格式 JSON
+	Version         string `json:"Version"`         // API 版本號 1.0
+	TimeStamp       string `json:"TimeStamp"`       // 時間戳記，Unix 格式
+	AllowanceStatus string `json:"AllowanceStatus"` // C=確認折讓, D=取消折讓
+	AllowanceNo     string `json:"AllowanceNo"`     // 折讓號
+	MerchantOrderNo string `json:"MerchantOrderNo"` // 商店訂單編號
+	TotalAmt        int    `json:"TotalAmt"`        // 折讓總金額
+}
+
+func (a Api) ConfirmAllowance(merchant *Merchant, allowance *Allowance, requestedAt xtime.Time) (*Allowance, error) {
+	return a.touchAllowance(merchant, allowance, "C", AllowanceStatusConfirmed, requestedAt)
+}
+
+func (a Api) CancelAllowance(merchant *Merchant, allowance *Allowance, requestedAt xtime.Time) (*Allowance, error) {
+	return a.touchAllowance(merchant, allowance, "D", AllowanceStatusCancelled, requestedAt)
+}
+
+func (a Api) touchAllowance(merchant *Merchant, allowance *Allowance, touchStatus string, status AllowanceStatus, requestedAt xtime.Time) (*Allowance, error) {
+	if allowance.Status != AllowanceStatusPending {
+		return nil, fmt.Errorf("[touch-allowance] allowance %s is %s", allowance.AllowanceNo, allowance.Status)
+	}
+
+	postData := TouchAllowancePostData{
+		RespondType:     "JSON",
+		Version:         "1.0",
+		TimeStamp:       strconv.FormatInt(time.Time(requestedAt).Unix(), 10),
+		AllowanceStatus: touchStatus,
+		AllowanceNo:     allowance.AllowanceNo,
+		MerchantOrderNo: allowance.MerchantOrderNo,
+		TotalAmt:        allowance.AllowanceAmt,
+	}
+
+	tp, err := postInvoiceForm(a.ApiUrlAllowanceTouch, merchant, postData, "touch-allowance")
+	if err != nil {
+		return nil, err
+	}
+
+	var result ResultInvoiceMemo
+	if err := tp.AssertString(&result); err != nil {
+		return nil, fmt.Errorf("[touch-allowance] assert: %v", err)
+	}
+
+	// 確認時剩餘可折讓金額已於開立折讓時扣除, 不依賴回應是否帶 RemainAmt; 取消時加回
+	touched := *allowance
+	touched.Status = status
+	if status == AllowanceStatusCancelled {
+		touched.RemainAmt = allowance.RemainAmt + allowance.AllowanceAmt
+	}
+
+	return &touched, nil
+}
+
+type InvalidAllowancePostData struct {
+	RespondType   string `json:"RespondType"`   // 回應格式 JSON
+	Version       string `json:"Version"`       // API 版本號 1.0
+	TimeStamp     string `json:"TimeStamp"`     // 時間戳記，Unix 格式
+	AllowanceNo   string `json:"AllowanceNo"`   // 折讓號
+	InvalidReason string `json:"InvalidReason"` // 作廢原因，中文 6 字或英數 20 字以內
+}
+
+// 作廢已確認的折讓, 作廢後發票可折讓金額會加回
+func (a Api) InvalidAllowance(merchant *Merchant, allowance *Allowance, invalidReason string, requestedAt xtime.Time) (*Allowance, *ResultAllowanceInvalid, error) {
+	if allowance.Status != AllowanceStatusConfirmed {
+		return nil, nil, fmt.Errorf("[invalid-allowance] allowance %s is %s", allowance.AllowanceNo, allowance.Status)
+	}
+
+	if !isValidInvalidReason(invalidReason) {
+		return nil, nil, errors.New("[invalid-allowance] invalid reason: " + invalidReason)
+	}
+
+	postData := InvalidAllowancePostData{
+		RespondType:   "JSON",
+		Version:       "1.0",
+		TimeStamp:     strconv.FormatInt(time.Time(requestedAt).Unix(), 10),
+		AllowanceNo:   allowance.AllowanceNo,
+		InvalidReason: invalidReason,
+	}
+
+	tp, err := postInvoiceForm(a.ApiUrlAllowanceInvalid, merchant, postData, "invalid-allowance")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var result ResultAllowanceInvalid
+	if err := tp.AssertString(&result); err != nil {
+		return nil, nil, fmt.Errorf("[invalid-allowance] assert: %v", err)
+	}
+
+	voided := *allowance
+	voided.Status = AllowanceStatusVoided
+	voided.RemainAmt = allowance.RemainAmt + allowance.AllowanceAmt
+
+	return &voided, &result, nil
+}
+
+type ResultAllowanceInvalid struct {
+	MerchantID  string `json:"MerchantID"`
+	AllowanceNo string `json:"AllowanceNo"`
+	CreateTime  string `json:"CreateTime"` // 作廢時間
+	CheckCode   string `json:"CheckCode"`
+}
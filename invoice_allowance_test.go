@@ -0,0 +1,94 @@
+package newebpay
+
+import (
+	"testing"
+
+	"github.com/Loopmaas/xtime"
+)
+
+func testPendingAllowance() *Allowance {
+	return &Allowance{
+		AllowanceNo:     "A113110100001",
+		InvoiceNumber:   "AB12345678",
+		MerchantOrderNo: "ORDER1",
+		AllowanceAmt:    300,
+		RemainAmt:       700,
+		Status:          AllowanceStatusPending,
+	}
+}
+
+func TestConfirmAllowance(t *testing.T) {
+	// 回應未帶 RemainAmt 時仍保留開立折讓時的剩餘可折讓金額
+	ezPay := newFakeEzPay(t, map[string]any{"AllowanceNo": "A113110100001"})
+	a := Api{ApiUrlAllowanceTouch: ezPay.URL}
+
+	pending := testPendingAllowance()
+	confirmed, err := a.ConfirmAllowance(testMerchant, pending, xtime.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if confirmed.Status != AllowanceStatusConfirmed || confirmed.RemainAmt != 700 || confirmed.AllowanceAmt != 300 {
+		t.Errorf("ConfirmAllowance() = %+v", confirmed)
+	}
+	if pending.Status != AllowanceStatusPending {
+		t.Errorf("original allowance changed: %+v", pending)
+	}
+	for key, want := range map[string]string{"AllowanceStatus": "C", "AllowanceNo": "A113110100001", "MerchantOrderNo": "ORDER1", "TotalAmt": "300"} {
+		if got := ezPay.postData.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	if _, err := a.ConfirmAllowance(testMerchant, confirmed, xtime.NowUTC()); err == nil {
+		t.Error("expected error confirming a confirmed allowance")
+	}
+}
+
+func TestCancelAllowance(t *testing.T) {
+	ezPay := newFakeEzPay(t, map[string]any{"AllowanceNo": "A113110100001", "RemainAmt": 0})
+	a := Api{ApiUrlAllowanceTouch: ezPay.URL}
+
+	cancelled, err := a.CancelAllowance(testMerchant, testPendingAllowance(), xtime.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cancelled.Status != AllowanceStatusCancelled || cancelled.RemainAmt != 1000 {
+		t.Errorf("CancelAllowance() = %+v", cancelled)
+	}
+	if got := ezPay.postData.Get("AllowanceStatus"); got != "D" {
+		t.Errorf("AllowanceStatus = %q, want D", got)
+	}
+
+	if _, err := a.CancelAllowance(testMerchant, cancelled, xtime.NowUTC()); err == nil {
+		t.Error("expected error cancelling a cancelled allowance")
+	}
+}
+
+func TestInvalidAllowance(t *testing.T) {
+	ezPay := newFakeEzPay(t, ResultAllowanceInvalid{AllowanceNo: "A113110100001"})
+	a := Api{ApiUrlAllowanceInvalid: ezPay.URL}
+
+	confirmed := testPendingAllowance()
+	confirmed.Status = AllowanceStatusConfirmed
+
+	voided, result, err := a.InvalidAllowance(testMerchant, confirmed, "折讓錯誤", xtime.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if voided.Status != AllowanceStatusVoided || voided.RemainAmt != 1000 || result.AllowanceNo != "A113110100001" {
+		t.Errorf("InvalidAllowance() = %+v, %+v", voided, result)
+	}
+	if got := ezPay.postData.Get("InvalidReason"); got != "折讓錯誤" {
+		t.Errorf("InvalidReason = %q", got)
+	}
+
+	if _, _, err := a.InvalidAllowance(testMerchant, testPendingAllowance(), "折讓錯誤", xtime.NowUTC()); err == nil {
+		t.Error("expected error voiding a pending allowance")
+	}
+	if _, _, err := a.InvalidAllowance(testMerchant, confirmed, "", xtime.NowUTC()); err == nil {
+		t.Error("expected error for empty reason")
+	}
+}
@@ -0,0 +1,209 @@
+package newebpay
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// 載具類別
+const (
+	CarrierTypeMobileBarcode = "0" // 手機條碼載具
+	CarrierTypeCitizenCert   = "1" // 自然人憑證條碼載具
+	CarrierTypeEzPay         = "2" // ezPay 電子發票載具
+)
+
+// 發票買受人, 依發票交付方式擇一:
+// PaperBuyer, MobileBarcodeBuyer, CitizenCertBuyer, EzPayCarrierBuyer, DonationBuyer, B2BBuyer
+type InvoiceBuyer interface {
+	Validate() error
+	Category() string
+	apply(postData *IssueInvoicePostData)
+}
+
+var citizenCertPattern = regexp.MustCompile(`^[A-Z]{2}[0-9]{14}$`)
+
+func validateBuyerContact(name, email string, emailRequired bool) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("missing buyer name")
+	}
+
+	if email == "" {
+		if emailRequired {
+			return errors.New("missing buyer email")
+		}
+		return nil
+	}
+
+	if _, err := mail.ParseAddress(email); err != nil {
+		return fmt.Errorf("invalid buyer email: %s", email)
+	}
+	return nil
+}
+
+// 索取紙本發票
+type PaperBuyer struct {
+	Name    string
+	Email   string
+	Address string // 選填
+}
+
+func (b PaperBuyer) Validate() error {
+	return validateBuyerContact(b.Name, b.Email, false)
+}
+
+func (b PaperBuyer) Category() string {
+	return InvoiceCategoryB2C
+}
+
+func (b PaperBuyer) apply(p *IssueInvoicePostData) {
+	p.Category = InvoiceCategoryB2C
+	p.BuyerName = b.Name
+	p.BuyerEmail = b.Email
+	if b.Address != "" {
+		p.BuyerAddress = &b.Address
+	}
+	p.PrintFlag = "Y"
+}
+
+// 手機條碼載具, 格式為 "/" 加 7 碼 [0-9A-Z+-.]
+type MobileBarcodeBuyer struct {
+	Name    string
+	Email   string
+	Barcode string
+}
+
+func (b MobileBarcodeBuyer) Validate() error {
+	if err := validateBuyerContact(b.Name, b.Email, false); err != nil {
+		return err
+	}
+
+	return ValidateMobileBarcode(b.Barcode)
+}
+
+func (b MobileBarcodeBuyer) Category() string {
+	return InvoiceCategoryB2C
+}
+
+func (b MobileBarcodeBuyer) apply(p *IssueInvoicePostData) {
+	p.Category = InvoiceCategoryB2C
+	p.BuyerName = b.Name
+	p.BuyerEmail = b.Email
+	p.CarrierType = CarrierTypeMobileBarcode
+	p.CarrierNum = b.Barcode
+	p.PrintFlag = "N"
+}
+
+// 自然人憑證條碼載具, 格式為 2 碼大寫英文加 14 碼數字
+type CitizenCertBuyer struct {
+	Name    string
+	Email   string
+	CertNum string
+}
+
+func (b CitizenCertBuyer) Validate() error {
+	if err := validateBuyerContact(b.Name, b.Email, false); err != nil {
+		return err
+	}
+
+	if !citizenCertPattern.MatchString(b.CertNum) {
+		return fmt.Errorf("invalid citizen digital certificate: %s", b.CertNum)
+	}
+	return nil
+}
+
+func (b CitizenCertBuyer) Category() string {
+	return InvoiceCategoryB2C
+}
+
+func (b CitizenCertBuyer) apply(p *IssueInvoicePostData) {
+	p.Category = InvoiceCategoryB2C
+	p.BuyerName = b.Name
+	p.BuyerEmail = b.Email
+	p.CarrierType = CarrierTypeCitizenCert
+	p.CarrierNum = b.CertNum
+	p.PrintFlag = "N"
+}
+
+// ezPay 電子發票載具, 以買受人 Email 歸戶
+type EzPayCarrierBuyer struct {
+	Name  string
+	Email string
+}
+
+func (b EzPayCarrierBuyer) Validate() error {
+	return validateBuyerContact(b.Name, b.Email, true)
+}
+
+func (b EzPayCarrierBuyer) Category() string {
+	return InvoiceCategoryB2C
+}
+
+func (b EzPayCarrierBuyer) apply(p *IssueInvoicePostData) {
+	p.Category = InvoiceCategoryB2C
+	p.BuyerName = b.Name
+	p.BuyerEmail = b.Email
+	p.CarrierType = CarrierTypeEzPay
+	p.CarrierNum = b.Email
+	p.PrintFlag = "N"
+}
+
+// 捐贈發票, 愛心碼為 3~7 碼數字
+type DonationBuyer struct {
+	Name     string
+	Email    string
+	LoveCode string
+}
+
+func (b DonationBuyer) Validate() error {
+	if err := validateBuyerContact(b.Name, b.Email, false); err != nil {
+		return err
+	}
+
+	return ValidateLoveCode(b.LoveCode)
+}
+
+func (b DonationBuyer) Category() string {
+	return InvoiceCategoryB2C
+}
+
+func (b DonationBuyer) apply(p *IssueInvoicePostData) {
+	p.Category = InvoiceCategoryB2C
+	p.BuyerName = b.Name
+	p.BuyerEmail = b.Email
+	p.LoveCode = &b.LoveCode
+	p.PrintFlag = "N"
+}
+
+// 營業人 (打統編) 存證發票, 發票品項以未稅金額開立; 未稅小計無法整除數量時品項數量會以 1 送出
+type B2BBuyer struct {
+	Name    string // 公司名稱
+	UBN     string // 統一編號
+	Address string // 公司地址 (選填)
+	Email   string
+}
+
+func (b B2BBuyer) Validate() error {
+	if err := validateBuyerContact(b.Name, b.Email, false); err != nil {
+		return err
+	}
+
+	return ValidateUBN(b.UBN)
+}
+
+func (b B2BBuyer) Category() string {
+	return InvoiceCategoryB2B
+}
+
+func (b B2BBuyer) apply(p *IssueInvoicePostData) {
+	p.Category = InvoiceCategoryB2B
+	p.BuyerName = b.Name
+	p.BuyerUBN = &b.UBN
+	if b.Address != "" {
+		p.BuyerAddress = &b.Address
+	}
+	p.BuyerEmail = b.Email
+	p.PrintFlag = "Y"
+}
@@ -0,0 +1,87 @@
+package newebpay
+
+import "testing"
+
+func TestInvoiceBuyerValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		buyer   InvoiceBuyer
+		wantErr bool
+	}{
+		{"紙本", PaperBuyer{Name: "王小明"}, false},
+		{"紙本缺姓名", PaperBuyer{Name: " ", Email: "a@b.com"}, true},
+		{"紙本信箱格式錯誤", PaperBuyer{Name: "王小明", Email: "not-an-email"}, true},
+		{"手機條碼", MobileBarcodeBuyer{Name: "王小明", Barcode: "/ABC+-.9"}, false},
+		{"手機條碼格式錯誤", MobileBarcodeBuyer{Name: "王小明", Barcode: "ABC1234"}, true},
+		{"自然人憑證", CitizenCertBuyer{Name: "王小明", CertNum: "AB12345678901234"}, false},
+		{"自然人憑證格式錯誤", CitizenCertBuyer{Name: "王小明", CertNum: "ab12345678901234"}, true},
+		{"ezPay 載具", EzPayCarrierBuyer{Name: "王小明", Email: "a@b.com"}, false},
+		{"ezPay 載具缺信箱", EzPayCarrierBuyer{Name: "王小明"}, true},
+		{"捐贈", DonationBuyer{Name: "王小明", LoveCode: "919"}, false},
+		{"捐贈愛心碼格式錯誤", DonationBuyer{Name: "王小明", LoveCode: "91"}, true},
+		{"營業人", B2BBuyer{Name: "某某有限公司", UBN: "04595257"}, false},
+		{"營業人統編錯誤", B2BBuyer{Name: "某某有限公司", UBN: "04595258"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.buyer.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInvoiceBuyerApply(t *testing.T) {
+	str := func(p *string) string {
+		if p == nil {
+			return "<nil>"
+		}
+		return *p
+	}
+
+	tests := []struct {
+		name        string
+		buyer       InvoiceBuyer
+		category    string
+		carrierType string
+		carrierNum  string
+		printFlag   string
+		ubn         string
+		address     string
+		loveCode    string
+	}{
+		{"紙本", PaperBuyer{Name: "王小明", Address: "台北市"}, InvoiceCategoryB2C, "", "", "Y", "<nil>", "台北市", "<nil>"},
+		{"手機條碼", MobileBarcodeBuyer{Name: "王小明", Barcode: "/ABC+-.9"}, InvoiceCategoryB2C, CarrierTypeMobileBarcode, "/ABC+-.9", "N", "<nil>", "<nil>", "<nil>"},
+		{"自然人憑證", CitizenCertBuyer{Name: "王小明", CertNum: "AB12345678901234"}, InvoiceCategoryB2C, CarrierTypeCitizenCert, "AB12345678901234", "N", "<nil>", "<nil>", "<nil>"},
+		{"ezPay 載具", EzPayCarrierBuyer{Name: "王小明", Email: "a@b.com"}, InvoiceCategoryB2C, CarrierTypeEzPay, "a@b.com", "N", "<nil>", "<nil>", "<nil>"},
+		{"捐贈", DonationBuyer{Name: "王小明", LoveCode: "919"}, InvoiceCategoryB2C, "", "", "N", "<nil>", "<nil>", "919"},
+		{"營業人", B2BBuyer{Name: "某某有限公司", UBN: "04595257"}, InvoiceCategoryB2B, "", "", "Y", "04595257", "<nil>", "<nil>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p IssueInvoicePostData
+			tt.buyer.apply(&p)
+
+			if p.Category != tt.category || tt.buyer.Category() != tt.category {
+				t.Errorf("Category = %s / %s, want %s", p.Category, tt.buyer.Category(), tt.category)
+			}
+			if p.CarrierType != tt.carrierType || p.CarrierNum != tt.carrierNum {
+				t.Errorf("Carrier = %q %q, want %q %q", p.CarrierType, p.CarrierNum, tt.carrierType, tt.carrierNum)
+			}
+			if p.PrintFlag != tt.printFlag {
+				t.Errorf("PrintFlag = %s, want %s", p.PrintFlag, tt.printFlag)
+			}
+			if got := str(p.BuyerUBN); got != tt.ubn {
+				t.Errorf("BuyerUBN = %s, want %s", got, tt.ubn)
+			}
+			if got := str(p.BuyerAddress); got != tt.address {
+				t.Errorf("BuyerAddress = %s, want %s", got, tt.address)
+			}
+			if got := str(p.LoveCode); got != tt.loveCode {
+				t.Errorf("LoveCode = %s, want %s", got, tt.loveCode)
+			}
+		})
+	}
+}
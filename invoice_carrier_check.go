@@ -0,0 +1,104 @@
+package newebpay
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+var (
+	mobileBarcodePattern = regexp.MustCompile(`^/[0-9A-Z+\-.]{7}$`)
+	loveCodePattern      = regexp.MustCompile(`^[0-9]{3,7}$`)
+)
+
+// 手機條碼格式: "/" 加 7 碼 [0-9A-Z+-.]
+func ValidateMobileBarcode(barcode string) error {
+	if !mobileBarcodePattern.MatchString(barcode) {
+		return fmt.Errorf("invalid mobile barcode: %s", barcode)
+	}
+	return nil
+}
+
+// 愛心碼格式: 3~7 碼數字
+func ValidateLoveCode(loveCode string) error {
+	if !loveCodePattern.MatchString(loveCode) {
+		return fmt.Errorf("invalid love code: %s", loveCode)
+	}
+	return nil
+}
+
+type CheckBarCodePostData struct {
+	TimeStamp        string `json:"TimeStamp"`        // 時間戳記，Unix 格式
+	Version          string `json:"Version"`          // API 版本號 1.0
+	CellphoneBarcode string `json:"CellphoneBarcode"` // 手機條碼
+}
+
+// 向財政部電子發票平台確認手機條碼是否存在, 格式錯誤時不呼叫 API
+func (a Api) CheckMobileBarcode(merchant *Merchant, barcode string, requestedAt xtime.Time) (bool, error) {
+	if err := ValidateMobileBarcode(barcode); err != nil {
+		return false, err
+	}
+
+	postData := CheckBarCodePostData{
+		TimeStamp:        strconv.FormatInt(time.Time(requestedAt).Unix(), 10),
+		Version:          "1.0",
+		CellphoneBarcode: barcode,
+	}
+
+	tp, err := postInvoiceForm(a.ApiUrlCheckBarCode, merchant, postData, "check-barcode")
+	if err != nil {
+		return false, err
+	}
+
+	var result ResultCarrierCheck
+	if err := tp.assertAny(&result); err != nil {
+		return false, fmt.Errorf("[check-barcode] assert: %v", err)
+	}
+
+	return result.Exists(), nil
+}
+
+type CheckLoveCodePostData struct {
+	TimeStamp string `json:"TimeStamp"` // 時間戳記，Unix 格式
+	Version   string `json:"Version"`   // API 版本號 1.0
+	LoveCode  string `json:"LoveCode"`  // 愛心碼
+}
+
+// 向財政部電子發票平台確認愛心碼 (捐贈碼) 是否存在, 格式錯誤時不呼叫 API
+func (a Api) CheckLoveCode(merchant *Merchant, loveCode string, requestedAt xtime.Time) (bool, error) {
+	if err := ValidateLoveCode(loveCode); err != nil {
+		return false, err
+	}
+
+	postData := CheckLoveCodePostData{
+		TimeStamp: strconv.FormatInt(time.Time(requestedAt).Unix(), 10),
+		Version:   "1.0",
+		LoveCode:  loveCode,
+	}
+
+	tp, err := postInvoiceForm(a.ApiUrlCheckLoveCode, merchant, postData, "check-love-code")
+	if err != nil {
+		return false, err
+	}
+
+	var result ResultCarrierCheck
+	if err := tp.assertAny(&result); err != nil {
+		return false, fmt.Errorf("[check-love-code] assert: %v", err)
+	}
+
+	return result.Exists(), nil
+}
+
+type ResultCarrierCheck struct {
+	MerchantID       string `json:"MerchantID"`
+	CellphoneBarcode string `json:"CellphoneBarcode,omitempty"`
+	LoveCode         string `json:"LoveCode,omitempty"`
+	IsExist          string `json:"IsExist"` // Y=存在, N=不存在
+}
+
+func (r ResultCarrierCheck) Exists() bool {
+	return r.IsExist == "Y"
+}
@@ -0,0 +1,90 @@
+package newebpay
+
+import (
+	"testing"
+
+	"github.com/Loopmaas/xtime"
+)
+
+func TestValidateMobileBarcode(t *testing.T) {
+	tests := []struct {
+		barcode string
+		valid   bool
+	}{
+		{"/ABC1234", true},
+		{"/+-.09AZ", true},
+		{"/abc1234", false},
+		{"ABC12345", false},
+		{"/ABC123", false},
+		{"/ABC12345", false},
+		{"/ABC 123", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateMobileBarcode(tt.barcode); (err == nil) != tt.valid {
+			t.Errorf("ValidateMobileBarcode(%q) = %v, want valid %v", tt.barcode, err, tt.valid)
+		}
+	}
+}
+
+func TestValidateLoveCode(t *testing.T) {
+	tests := []struct {
+		loveCode string
+		valid    bool
+	}{
+		{"919", true},
+		{"1234567", true},
+		{"91", false},
+		{"12345678", false},
+		{"91a", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateLoveCode(tt.loveCode); (err == nil) != tt.valid {
+			t.Errorf("ValidateLoveCode(%q) = %v, want valid %v", tt.loveCode, err, tt.valid)
+		}
+	}
+}
+
+func TestCheckMobileBarcode(t *testing.T) {
+	for _, isExist := range []string{"Y", "N"} {
+		t.Run(isExist, func(t *testing.T) {
+			ezPay := newFakeEzPay(t, ResultCarrierCheck{CellphoneBarcode: "/ABC1234", IsExist: isExist})
+			a := Api{ApiUrlCheckBarCode: ezPay.URL}
+
+			exists, err := a.CheckMobileBarcode(testMerchant, "/ABC1234", xtime.NowUTC())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists != (isExist == "Y") {
+				t.Errorf("CheckMobileBarcode() = %v, IsExist %s", exists, isExist)
+			}
+			if got := ezPay.postData.Get("CellphoneBarcode"); got != "/ABC1234" {
+				t.Errorf("CellphoneBarcode = %q", got)
+			}
+		})
+	}
+
+	a := Api{ApiUrlCheckBarCode: "http://127.0.0.1:0"}
+	if _, err := a.CheckMobileBarcode(testMerchant, "ABC1234", xtime.NowUTC()); err == nil {
+		t.Error("expected format error before calling the API")
+	}
+}
+
+// ezPay 的 Result 可能為物件而非 JSON 字串
+func TestCheckLoveCodeObjectResult(t *testing.T) {
+	srv := newFakeNewebPay(t, ResultCarrierCheck{LoveCode: "919", IsExist: "Y"})
+	a := Api{ApiUrlCheckLoveCode: srv.URL}
+
+	exists, err := a.CheckLoveCode(testMerchant, "919", xtime.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("CheckLoveCode() = false, want true")
+	}
+
+	if _, err := a.CheckLoveCode(testMerchant, "91", xtime.NowUTC()); err == nil {
+		t.Error("expected format error before calling the API")
+	}
+}
@@ -0,0 +1,82 @@
+package newebpay
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Loopmaas/xtime"
+)
+
+type InvalidInvoicePostData struct {
+	RespondType   string `json:"RespondType"`   // 回應格式 JSON
+	Version       string `json:"Version"`       // API 版本號 1.0
+	TimeStamp     string `json:"TimeStamp"`     // 時間戳記，Unix 格式
+	InvoiceNumber string `json:"InvoiceNumber"` // 發票號碼
+	InvalidReason string `json:"InvalidReason"` // 作廢原因，中文 6 字或英數 20 字以內
+}
+
+// 發票作廢: 僅限當期 (雙月) 開立的發票
+func (a Api) InvalidInvoice(merchant *Merchant, invoiceNumber, invalidReason string, requestedAt xtime.Time) (*RespInvoiceInvalid, error) {
+	if invoiceNumber == "" {
+		return nil, errors.New("Missing invoice number")
+	}
+
+	if !isValidInvalidReason(invalidReason) {
+		return nil, fmt.Errorf("invalid reason: %s", invalidReason)
+	}
+
+	postData := InvalidInvoicePostData{
+		RespondType:   "JSON",
+		Version:       "1.0",
+		TimeStamp:     strconv.FormatInt(time.Time(requestedAt).Unix(), 10),
+		InvoiceNumber: invoiceNumber,
+		InvalidReason: invalidReason,
+	}
+
+	tp, err := postInvoiceForm(a.ApiUrlInvoiceInvalid, merchant, postData, "invalid-invoice")
+	if err != nil {
+		return nil, err
+	}
+
+	payload := RespInvoiceInvalid{
+		Status:  tp.Status,
+		Message: tp.Message,
+	}
+	if err := tp.AssertString(&payload.Result); err != nil {
+		return nil, fmt.Errorf("[invalid-invoice] assert: %v", err)
+	}
+
+	return &payload, nil
+}
+
+// 中文 6 字或英數 20 字以內
+func isValidInvalidReason(reason string) bool {
+	if reason == "" {
+		return false
+	}
+
+	if utf8.RuneCountInString(reason) == len(reason) {
+		return len(reason) <= 20
+	}
+	return utf8.RuneCountInString(reason) <= 6
+}
+
+type RespInvoiceInvalid struct {
+	Status  string               `json:"Status"`
+	Message string               `json:"Message"`
+	Result  ResultInvoiceInvalid `json:"Result"`
+}
+
+func (r RespInvoiceInvalid) IsSuccess() bool {
+	return r.Status == "SUCCESS"
+}
+
+type ResultInvoiceInvalid struct {
+	MerchantID    string `json:"MerchantID"`
+	InvoiceNumber string `json:"InvoiceNumber"`
+	CreateTime    string `json:"CreateTime"` // 作廢時間
+	CheckCode     string `json:"CheckCode"`
+}
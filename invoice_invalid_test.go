@@ -0,0 +1,57 @@
+package newebpay
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Loopmaas/xtime"
+)
+
+func TestIsValidInvalidReason(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   bool
+	}{
+		{"", false},
+		{"wrong", true},
+		{strings.Repeat("a", 20), true},
+		{strings.Repeat("a", 21), false},
+		{"開錯發票", true},
+		{"發票金額錯誤", true},
+		{"發票金額開立錯誤", false},
+		{"金額錯誤x", true},
+		{"金額錯誤abc", false},
+	}
+
+	for _, tt := range tests {
+		if got := isValidInvalidReason(tt.reason); got != tt.want {
+			t.Errorf("isValidInvalidReason(%q) = %v, want %v", tt.reason, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidInvoice(t *testing.T) {
+	ezPay := newFakeEzPay(t, ResultInvoiceInvalid{InvoiceNumber: "AB12345678"})
+	a := Api{ApiUrlInvoiceInvalid: ezPay.URL}
+
+	resp, err := a.InvalidInvoice(testMerchant, "AB12345678", "開錯發票", xtime.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result.InvoiceNumber != "AB12345678" {
+		t.Errorf("Result = %+v", resp.Result)
+	}
+	if got := ezPay.postData.Get("InvalidReason"); got != "開錯發票" {
+		t.Errorf("InvalidReason = %q", got)
+	}
+	if got := ezPay.postData.Get("InvoiceNumber"); got != "AB12345678" {
+		t.Errorf("InvoiceNumber = %q", got)
+	}
+
+	if _, err := a.InvalidInvoice(testMerchant, "AB12345678", "發票金額開立錯誤", xtime.NowUTC()); err == nil {
+		t.Error("expected error for reason over 6 Chinese characters")
+	}
+	if _, err := a.InvalidInvoice(testMerchant, "", "開錯發票", xtime.NowUTC()); err == nil {
+		t.Error("expected error for missing invoice number")
+	}
+}
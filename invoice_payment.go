@@ -0,0 +1,179 @@
+package newebpay
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+// 付款成功的結果, ResultTransaction 及 ResultMPGTradeInfo
+type PaymentResult interface {
+	paidOrder() (merchantOrderNo, tradeNo string, amount int)
+}
+
+var (
+	_ PaymentResult = ResultTransaction{}
+	_ PaymentResult = ResultMPGTradeInfo{}
+)
+
+// 依付款結果開立發票, 以付款的 MerchantOrderNo 開立, 品項總額須等於付款金額。
+// 開立前先查詢發票, 同一訂單已開立且未作廢時直接回傳既有發票, 第二個回傳值為是否新開立。
+func (a Api) IssueInvoiceForPayment(merchant *Merchant, payment PaymentResult, buyer InvoiceBuyer,
+	items []*InvoiceItem, requestedAt xtime.Time,
+) (*ResultInvoiceIssue, bool, error) {
+	merchantOrderNo, tradeNo, amount := payment.paidOrder()
+	if merchantOrderNo == "" || amount <= 0 {
+		return nil, false, errors.New("[payment-invoice] invalid payment result")
+	}
+
+	if buyer == nil {
+		return nil, false, errors.New("[payment-invoice] missing buyer")
+	}
+
+	amounts, err := CalcInvoiceAmounts(buyer.Category(), items)
+	if err != nil {
+		return nil, false, fmt.Errorf("[payment-invoice] %w", err)
+	}
+
+	if amounts.TotalAmt != amount {
+		return nil, false, fmt.Errorf("[payment-invoice] invoice total %d does not match payment amount %d, tradeNo: %s", amounts.TotalAmt, amount, tradeNo)
+	}
+
+	existing, err := a.SearchInvoiceByMerchantOrderNo(merchant, merchantOrderNo, amount, requestedAt)
+	var invoiceErr *InvoiceError
+	switch {
+	case err == nil && !existing.Result.IsVoided():
+		return existing.Result.issueResult(), false, nil
+	case err == nil:
+		return nil, false, fmt.Errorf("[payment-invoice] invoice %s of order %s is voided", existing.Result.InvoiceNumber, merchantOrderNo)
+	case !errors.As(err, &invoiceErr) || !invoiceErr.IsNotFound():
+		return nil, false, err
+	}
+
+	// 僅 ezPay 回傳查無發票時視為尚未開立, 其餘錯誤 (如金鑰錯誤、金額不符) 不可重複開立
+	issued, err := a.IssueInvoice(merchant, buyer, merchantOrderNo, items, requestedAt)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &issued.Result, true, nil
+}
+
+func (r ResultInvoiceSearch) issueResult() *ResultInvoiceIssue {
+	result := ResultInvoiceIssue{
+		MerchantID:      r.MerchantID,
+		InvoiceTransNo:  r.InvoiceTransNo,
+		MerchantOrderNo: r.MerchantOrderNo,
+		TotalAmt:        int(r.TotalAmt),
+		InvoiceNumber:   r.InvoiceNumber,
+		RandomNum:       r.RandomNum,
+		CreateTime:      r.CreateTime,
+		CheckCode:       r.CheckCode,
+	}
+
+	if r.BarCode != "" {
+		result.BarCode = &r.BarCode
+	}
+	if r.QRcodeL != "" {
+		result.QRcodeL = &r.QRcodeL
+	}
+	if r.QRcodeR != "" {
+		result.QRcodeR = &r.QRcodeR
+	}
+
+	return &result
+}
+
+// 退款時開立折讓或作廢發票所需的資料
+type InvoiceRefund struct {
+	BuyerName  string
+	BuyerEmail string
+	Category   string         // 原發票類別 B2B 或 B2C, 空值視為 B2C
+	Items      []*InvoiceItem // 折讓品項, 空值時以單一應稅品項折讓退款金額
+	Reason     string         // 作廢原因, 空值時為 "退款"
+}
+
+type PaymentRefundResult struct {
+	Payment   *RespCreditCardBehavior // Retain 結果, 保留金額等於交易金額時為 nil
+	Invalid   *ResultInvoiceInvalid   // 作廢的發票
+	Allowance *Allowance              // 開立並確認的折讓
+}
+
+// 以 Retain 保留 amount 並退還其餘金額, 接著依退款金額處理發票:
+// 當期發票全額退款時作廢發票, 部分退款或已跨期時就退款金額開立折讓並立即確認。
+func (a Api) RetainWithInvoice(merchant *Merchant, trade *RespQueryTradeInfo, invoice *ResultInvoiceIssue,
+	amount int, refund InvoiceRefund, requestedAt xtime.Time,
+) (*PaymentRefundResult, error) {
+	if trade.Result.MerchantOrderNo != invoice.MerchantOrderNo {
+		return nil, fmt.Errorf("[payment-refund] invoice %s does not belong to order %s", invoice.InvoiceNumber, trade.Result.MerchantOrderNo)
+	}
+
+	if amount < 0 || amount > trade.Result.Amt {
+		return nil, fmt.Errorf("[payment-refund] invalid retain amount: %d", amount)
+	}
+
+	refundAmount := trade.Result.Amt - amount
+	if refundAmount == 0 {
+		return &PaymentRefundResult{}, nil
+	}
+
+	items := refund.Items
+	if len(items) == 0 {
+		items = []*InvoiceItem{{Name: "退款", Count: 1, Unit: "式", Price: refundAmount}}
+	}
+
+	if total := invoiceItemsTotal(items); total != refundAmount {
+		return nil, fmt.Errorf("[payment-refund] allowance total %d does not match refund amount %d", total, refundAmount)
+	}
+
+	reason := refund.Reason
+	if reason == "" {
+		reason = "退款"
+	}
+
+	createTime, err := parseInvoiceTime(invoice.CreateTime)
+	if err != nil {
+		return nil, fmt.Errorf("[payment-refund] %w", err)
+	}
+
+	canVoid, err := CanVoidInvoice(createTime, time.Time(requestedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	behavior, err := trade.Retain(&a, merchant, amount, requestedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	result := PaymentRefundResult{Payment: behavior}
+	if amount == 0 && canVoid {
+		invalid, err := a.InvalidInvoice(merchant, invoice.InvoiceNumber, reason, requestedAt)
+		if err != nil {
+			return &result, fmt.Errorf("[payment-refund] payment refunded but invoice not voided: %w", err)
+		}
+
+		result.Invalid = &invalid.Result
+		return &result, nil
+	}
+
+	allowance, err := a.MemoInvoiceWithOptions(merchant, refund.BuyerName, refund.BuyerEmail, invoice.InvoiceNumber, invoice.MerchantOrderNo, items, requestedAt, MemoInvoiceOptions{
+		Category: refund.Category,
+	})
+	if err != nil {
+		return &result, fmt.Errorf("[payment-refund] payment refunded but allowance not issued: %w", err)
+	}
+
+	result.Allowance = allowance
+	return &result, nil
+}
+
+func invoiceItemsTotal(items []*InvoiceItem) int {
+	total := 0
+	for _, item := range items {
+		total += item.Amount()
+	}
+	return total
+}
@@ -0,0 +1,189 @@
+package newebpay
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+func TestIssueInvoiceForPaymentSearchError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		wantIssued bool
+	}{
+		{"not found", InvoiceSearchStatusNotFound, true},
+		{"invalid key", "KEY10002", false},
+		{"amount mismatch", "INV90006", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := newFakeEzPay(t, nil)
+			search.status, search.message = tt.status, "error"
+			issue := newFakeEzPay(t, ResultInvoiceIssue{MerchantOrderNo: "ORDER1", InvoiceNumber: "AB12345678"})
+			a := Api{ApiUrlInvoiceSearch: search.URL, ApiUrlInvoiceIssue: issue.URL}
+
+			payment := ResultTransaction{MerchantOrderNo: "ORDER1", TradeNo: "T1", Amt: 300}
+			buyer := B2BBuyer{Name: "某某有限公司", UBN: "04595257", Email: "a@b.com"}
+			items := []*InvoiceItem{{Name: "租金", Count: 3, Unit: "日", Price: 100}}
+			result, issued, err := a.IssueInvoiceForPayment(testMerchant, payment, buyer, items, xtime.NowUTC())
+
+			if tt.wantIssued {
+				if err != nil || !issued || result.InvoiceNumber != "AB12345678" {
+					t.Errorf("IssueInvoiceForPayment() = %+v, %v, %v", result, issued, err)
+				}
+				return
+			}
+
+			var invoiceErr *InvoiceError
+			if !errors.As(err, &invoiceErr) || invoiceErr.Status != tt.status {
+				t.Errorf("IssueInvoiceForPayment() error = %v, want search InvoiceError", err)
+			}
+			if issue.postData != nil {
+				t.Error("invoice issued after search error")
+			}
+		})
+	}
+}
+
+// 已請款完成的交易, 退款時呼叫 CreditCardRefundRequest
+func testRetainTrade(t *testing.T) *RespQueryTradeInfo {
+	t.Helper()
+	return &RespQueryTradeInfo{Status: "SUCCESS", Result: testQueryResult(t, "ORDER1", 1000)}
+}
+
+func testRetainInvoice(createTime string) *ResultInvoiceIssue {
+	return &ResultInvoiceIssue{MerchantOrderNo: "ORDER1", InvoiceNumber: "AB12345678", TotalAmt: 1000, CreateTime: createTime}
+}
+
+type retainFakes struct {
+	close   *fakeNewebPay
+	invalid *fakeEzPay
+	memo    *fakeEzPay
+	api     Api
+}
+
+func newRetainFakes(t *testing.T) *retainFakes {
+	t.Helper()
+
+	checkCode, err := genCheckCode(1000, testMerchant.MerchantId, "ORDER1", "T1", testMerchant.HashKey, testMerchant.HashIv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &retainFakes{
+		close:   newFakeNewebPay(t, ResultCreditCardBehavior{MerchantID: testMerchant.MerchantId, TradeNo: "T1", Amt: 1000, MerchantOrderNo: "ORDER1", CheckCode: &checkCode}),
+		invalid: newFakeEzPay(t, ResultInvoiceInvalid{InvoiceNumber: "AB12345678"}),
+		memo:    newFakeEzPay(t, ResultInvoiceMemo{AllowanceNo: "A1", InvoiceNumber: "AB12345678", MerchantOrderNo: "ORDER1"}),
+	}
+	f.api = Api{ApiUrlCreditCardClose: f.close.URL, ApiUrlInvoiceInvalid: f.invalid.URL, ApiUrlInvoiceMemo: f.memo.URL}
+	return f
+}
+
+func (f *retainFakes) refundAmt(t *testing.T) string {
+	t.Helper()
+
+	if f.close.postForm == nil {
+		return ""
+	}
+	postData, err := decryptTestPostData(f.close.postForm.Get("PostData_"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return postData.Get("Amt")
+}
+
+func TestRetainWithInvoice(t *testing.T) {
+	// 2024-03-15 12:00 (Asia/Taipei), 當期為 3-4 月
+	requestedAt := xtime.Time(time.Date(2024, 3, 15, 4, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name          string
+		createTime    string
+		amount        int
+		wantRefund    string
+		wantVoid      bool
+		wantAllowance string
+	}{
+		{"當期全額退款作廢", "2024-03-02 10:00:00", 0, "1000", true, ""},
+		{"跨期全額退款折讓", "2024-02-20 10:00:00", 0, "1000", false, "1000"},
+		{"當期部分退款折讓", "2024-03-02 10:00:00", 600, "400", false, "400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRetainFakes(t)
+			result, err := f.api.RetainWithInvoice(testMerchant, testRetainTrade(t), testRetainInvoice(tt.createTime), tt.amount, InvoiceRefund{}, requestedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := f.refundAmt(t); got != tt.wantRefund {
+				t.Errorf("refund Amt = %q, want %q", got, tt.wantRefund)
+			}
+			if result.Payment == nil {
+				t.Error("Payment = nil")
+			}
+			if tt.wantVoid {
+				if result.Invalid == nil || f.invalid.postData.Get("InvalidReason") != "退款" || f.memo.postData != nil {
+					t.Errorf("expected void only: %+v", result)
+				}
+				return
+			}
+			if result.Allowance == nil || result.Allowance.Status != AllowanceStatusConfirmed || f.invalid.postData != nil {
+				t.Errorf("expected allowance only: %+v", result)
+			}
+			if got := f.memo.postData.Get("TotalAmt"); got != tt.wantAllowance {
+				t.Errorf("allowance TotalAmt = %q, want %q", got, tt.wantAllowance)
+			}
+		})
+	}
+}
+
+func TestRetainWithInvoiceItemsMismatch(t *testing.T) {
+	f := newRetainFakes(t)
+	refund := InvoiceRefund{Items: []*InvoiceItem{{Name: "租金", Count: 1, Unit: "日", Price: 300}}}
+
+	if _, err := f.api.RetainWithInvoice(testMerchant, testRetainTrade(t), testRetainInvoice("2024-03-02 10:00:00"), 600, refund, xtime.NowUTC()); err == nil {
+		t.Fatal("expected error for allowance items not matching the refund amount")
+	}
+	if f.close.postForm != nil || f.memo.postData != nil {
+		t.Error("no request should be sent when allowance items do not match")
+	}
+}
+
+// 退款成功但發票步驟失敗時回傳已完成的退款結果, 由呼叫端補開折讓或作廢
+func TestRetainWithInvoicePartialFailure(t *testing.T) {
+	requestedAt := xtime.Time(time.Date(2024, 3, 15, 4, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name   string
+		amount int
+		fail   func(f *retainFakes)
+	}{
+		{"作廢失敗", 0, func(f *retainFakes) { f.invalid.status, f.invalid.message = "INV20006", "發票已作廢" }},
+		{"折讓失敗", 600, func(f *retainFakes) {
+			f.memo.status, f.memo.message = "ALW10001", "折讓金額超過發票可折讓金額"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRetainFakes(t)
+			tt.fail(f)
+
+			result, err := f.api.RetainWithInvoice(testMerchant, testRetainTrade(t), testRetainInvoice("2024-03-02 10:00:00"), tt.amount, InvoiceRefund{}, requestedAt)
+			var invoiceErr *InvoiceError
+			if !errors.As(err, &invoiceErr) {
+				t.Fatalf("err = %v, want wrapped InvoiceError", err)
+			}
+			if result == nil || result.Payment == nil || result.Invalid != nil || result.Allowance != nil {
+				t.Errorf("result = %+v, want payment only", result)
+			}
+			if f.refundAmt(t) == "" {
+				t.Error("payment refund was not sent")
+			}
+		})
+	}
+}
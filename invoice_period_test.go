@@ -0,0 +1,165 @@
+package newebpay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+func TestInvoicePeriodOf(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		t         time.Time
+		want      InvoicePeriod
+		wantStr   string
+		wantLabel string
+	}{
+		{"january", time.Date(2024, 1, 1, 0, 0, 0, 0, location), InvoicePeriod{113, 1}, "11302", "113年01-02月"},
+		{"december", time.Date(2024, 12, 31, 23, 59, 59, 0, location), InvoicePeriod{113, 6}, "11312", "113年11-12月"},
+		{"utc before taipei new year", time.Date(2024, 12, 31, 16, 0, 0, 0, time.UTC), InvoicePeriod{114, 1}, "11402", "114年01-02月"},
+		{"odd month", time.Date(2024, 7, 15, 0, 0, 0, 0, location), InvoicePeriod{113, 4}, "11308", "113年07-08月"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InvoicePeriodOf(tt.t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || got.String() != tt.wantStr || got.Label() != tt.wantLabel {
+				t.Errorf("InvoicePeriodOf() = %+v, %s, %s", got, got.String(), got.Label())
+			}
+		})
+	}
+}
+
+func TestInvoicePeriodStartNext(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := InvoicePeriod{Year: 113, Term: 6}
+	start, err := p.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !start.Equal(time.Date(2024, 11, 1, 0, 0, 0, 0, location)) {
+		t.Errorf("Start() = %v", start)
+	}
+
+	if next := p.Next(); next != (InvoicePeriod{Year: 114, Term: 1}) {
+		t.Errorf("Next() = %+v", next)
+	}
+	if next := (InvoicePeriod{Year: 113, Term: 3}).Next(); next != (InvoicePeriod{Year: 113, Term: 4}) {
+		t.Errorf("Next() = %+v", next)
+	}
+}
+
+func TestCanVoidInvoice(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2024, 11, 15, 12, 0, 0, 0, location)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"same month", time.Date(2024, 11, 30, 0, 0, 0, 0, location), true},
+		{"same period", time.Date(2024, 12, 31, 23, 59, 59, 0, location), true},
+		{"next period in taipei", time.Date(2024, 12, 31, 16, 0, 0, 0, time.UTC), false},
+		{"previous period", time.Date(2024, 10, 31, 0, 0, 0, 0, location), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CanVoidInvoice(created, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CanVoidInvoice() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultInvoiceIssuePeriod(t *testing.T) {
+	period, err := ResultInvoiceIssue{CreateTime: "2024-03-01 00:00:00"}.Period()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if period != (InvoicePeriod{Year: 113, Term: 2}) {
+		t.Errorf("Period() = %+v", period)
+	}
+
+	if _, err := (ResultInvoiceIssue{CreateTime: "2024/03/01"}).Period(); err == nil {
+		t.Error("expected invalid create time error")
+	}
+}
+
+func TestInvoiceNumberTrackRemaining(t *testing.T) {
+	tests := []struct {
+		name          string
+		track         InvoiceNumberTrack
+		wantTotal     int
+		wantRemaining int
+	}{
+		{"unused", InvoiceNumberTrack{StartNumber: "00000000", EndNumber: "00000049"}, 50, 50},
+		{"partially used", InvoiceNumberTrack{StartNumber: "00000000", EndNumber: "00000049", LastNumber: "00000009"}, 50, 40},
+		{"used up", InvoiceNumberTrack{StartNumber: "00000050", EndNumber: "00000099", LastNumber: "00000099"}, 50, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, err := tt.track.Total()
+			if err != nil {
+				t.Fatal(err)
+			}
+			remaining, err := tt.track.Remaining()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != tt.wantTotal || remaining != tt.wantRemaining {
+				t.Errorf("Total() = %d, Remaining() = %d, want %d, %d", total, remaining, tt.wantTotal, tt.wantRemaining)
+			}
+		})
+	}
+
+	if _, err := (InvoiceNumberTrack{StartNumber: "A", EndNumber: "1"}).Total(); err == nil {
+		t.Error("expected invalid start number error")
+	}
+}
+
+func TestSearchInvoiceNumber(t *testing.T) {
+	ezPay := newFakeEzPay(t, nil)
+	a := Api{ApiUrlInvoiceNumber: ezPay.URL}
+
+	if _, err := a.SearchInvoiceNumber(testMerchant, InvoicePeriod{Year: 113, Term: 7}, "", xtime.NowUTC()); err == nil {
+		t.Error("expected invalid period error")
+	}
+	if ezPay.postData != nil {
+		t.Error("invalid period sent to ezPay")
+	}
+
+	ezPay.result = []map[string]any{{"Year": 113, "Term": 6, "AphabeticLetter": "AB", "StartNumber": "00000000", "EndNumber": "00000049", "Flag": "1"}}
+	tracks, err := a.SearchInvoiceNumber(testMerchant, InvoicePeriod{Year: 113, Term: 6}, InvoiceNumberFlagEnabled, xtime.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 1 || tracks[0].Year != "113" || tracks[0].AphabeticLetter != "AB" {
+		t.Errorf("SearchInvoiceNumber() = %+v", tracks)
+	}
+	if year, term := ezPay.postData.Get("Year"), ezPay.postData.Get("Term"); year != "113" || term != "6" {
+		t.Errorf("Year = %s, Term = %s", year, term)
+	}
+}
@@ -0,0 +1,238 @@
+package newebpay
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 財政部電子發票證明聯二維條碼加密驗證資訊固定使用的 IV
+const invoiceQRCodeIv = "Dt8lyToo17X/XkXaQvihuA=="
+
+// 單一二維條碼的最大長度 (bytes), 超過的品目改記載於右方條碼或省略
+const invoiceQRCodeMaxBytes = 250
+
+// 電子發票證明聯一維條碼及二維條碼所需的發票資訊
+type InvoiceProof struct {
+	InvoiceNumber string    // 發票號碼
+	RandomNum     string    // 發票防偽隨機碼
+	CreateTime    time.Time // 開立時間
+	SellerUBN     string    // 賣方統一編號
+	BuyerUBN      string    // 買方統一編號, B2C 空值
+	SalesAmt      int       // 銷售額 (未稅)
+	TotalAmt      int       // 總計 (含稅)
+	Items         []InvoiceProofItem
+}
+
+type InvoiceProofItem struct {
+	Name  string
+	Count int
+	Price int // 單價
+}
+
+// 以開立結果及計算的發票金額產生證明聯資訊
+func NewInvoiceProof(result *ResultInvoiceIssue, amounts *InvoiceAmounts, sellerUBN, buyerUBN string) (*InvoiceProof, error) {
+	createTime, err := parseInvoiceTime(result.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]InvoiceProofItem, len(amounts.Lines))
+	for i, line := range amounts.Lines {
+		items[i] = InvoiceProofItem{
+			Name:  line.Item.Name,
+			Count: line.Count,
+			Price: line.Price,
+		}
+	}
+
+	return &InvoiceProof{
+		InvoiceNumber: result.InvoiceNumber,
+		RandomNum:     result.RandomNum,
+		CreateTime:    createTime,
+		SellerUBN:     sellerUBN,
+		BuyerUBN:      buyerUBN,
+		SalesAmt:      amounts.Amt,
+		TotalAmt:      amounts.TotalAmt,
+		Items:         items,
+	}, nil
+}
+
+// 以查詢結果產生證明聯資訊, 用於重新產生開立時未回傳的條碼
+func NewInvoiceProofFromSearch(result *ResultInvoiceSearch, sellerUBN string) (*InvoiceProof, error) {
+	createTime, err := parseInvoiceTime(result.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+
+	searchItems, err := result.Items()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]InvoiceProofItem, len(searchItems))
+	for i, item := range searchItems {
+		price, err := strconv.ParseFloat(string(item.ItemPrice), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid item price: %s", item.ItemPrice)
+		}
+
+		items[i] = InvoiceProofItem{
+			Name:  item.ItemName,
+			Count: int(item.ItemCount),
+			Price: int(math.Round(price)),
+		}
+	}
+
+	return &InvoiceProof{
+		InvoiceNumber: result.InvoiceNumber,
+		RandomNum:     result.RandomNum,
+		CreateTime:    createTime,
+		SellerUBN:     sellerUBN,
+		BuyerUBN:      result.BuyerUBN,
+		SalesAmt:      int(result.Amt),
+		TotalAmt:      int(result.TotalAmt),
+		Items:         items,
+	}, nil
+}
+
+// ezPay 回傳的開立時間 YYYY-MM-DD HH:mm:ss, 為台灣時間
+func parseInvoiceTime(value string) (time.Time, error) {
+	location, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid invoice create time: %s", value)
+	}
+	return t, nil
+}
+
+func (p InvoiceProof) validate() error {
+	switch {
+	case len(p.InvoiceNumber) != 10:
+		return fmt.Errorf("invalid invoice number: %s", p.InvoiceNumber)
+	case len(p.RandomNum) != 4:
+		return fmt.Errorf("invalid random number: %s", p.RandomNum)
+	case p.CreateTime.IsZero():
+		return errors.New("missing invoice create time")
+	}
+	return nil
+}
+
+// 一維條碼 (Code39) 內容: 期別 (5) + 發票號碼 (10) + 隨機碼 (4)
+func (p InvoiceProof) BarcodePayload() (string, error) {
+	if err := p.validate(); err != nil {
+		return "", err
+	}
+
+	period, err := InvoicePeriodOf(p.CreateTime)
+	if err != nil {
+		return "", err
+	}
+
+	return period.String() + p.InvoiceNumber + p.RandomNum, nil
+}
+
+// 左右二維條碼內容, qrKey 為 ezPay 後台設定的 32 碼十六進位 QR Code 加密金鑰
+func (p InvoiceProof) QRCodePayloads(qrKey string) (left, right string, err error) {
+	if err := p.validate(); err != nil {
+		return "", "", err
+	}
+
+	if err := ValidateUBN(p.SellerUBN); err != nil {
+		return "", "", err
+	}
+
+	buyerUBN := p.BuyerUBN
+	if buyerUBN == "" {
+		buyerUBN = "00000000"
+	}
+
+	encrypted, err := encryptInvoiceQRCode(p.InvoiceNumber+p.RandomNum, qrKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	location, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		return "", "", err
+	}
+
+	t := p.CreateTime.In(location)
+	header := fmt.Sprintf("%s%03d%02d%02d%s%08x%08x%s%s%s",
+		p.InvoiceNumber,
+		t.Year()-1911, int(t.Month()), t.Day(),
+		p.RandomNum,
+		p.SalesAmt,
+		p.TotalAmt,
+		buyerUBN,
+		p.SellerUBN,
+		encrypted,
+	)
+
+	entries := make([]string, len(p.Items))
+	for i, item := range p.Items {
+		name := strings.ReplaceAll(item.Name, ":", "：")
+		entries[i] = fmt.Sprintf("%s:%d:%d", name, item.Count, item.Price)
+	}
+
+	// 營業人自行使用區 (10) : 二維條碼記載完整品目筆數 : 該張發票交易品目總筆數 : 中文編碼參數 (1=UTF-8)
+	leftBase := len(header) + len(":**********:") + 2*len(strconv.Itoa(len(entries))) + len("::1")
+	n := 0
+	size := leftBase
+	for n < len(entries) && size+1+len(entries[n]) <= invoiceQRCodeMaxBytes {
+		size += 1 + len(entries[n])
+		n++
+	}
+	leftItems := entries[:n]
+
+	m := n
+	size = len("**")
+	for m < len(entries) && size+len(entries[m])+1 <= invoiceQRCodeMaxBytes {
+		size += len(entries[m]) + 1
+		m++
+	}
+	rightItems := entries[n:m]
+
+	left = fmt.Sprintf("%s:**********:%d:%d:1", header, m, len(entries))
+	if len(leftItems) > 0 {
+		left += ":" + strings.Join(leftItems, ":")
+	}
+	right = "**" + strings.Join(rightItems, ":")
+
+	return left, right, nil
+}
+
+// AES-128-CBC 加密 發票號碼 + 隨機碼, 以 base64 輸出 24 碼
+func encryptInvoiceQRCode(plaintext, qrKey string) (string, error) {
+	key, err := hex.DecodeString(qrKey)
+	if err != nil || len(key) != 16 {
+		return "", errors.New("invalid invoice QR code key")
+	}
+
+	iv, err := base64.StdEncoding.DecodeString(invoiceQRCodeIv)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	paddedData := PKCS7Padding([]byte(plaintext), block.BlockSize())
+	ciphertext := make([]byte, len(paddedData))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, paddedData)
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
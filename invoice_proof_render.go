@@ -0,0 +1,400 @@
+package newebpay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// 電子發票證明聯版面
+type InvoiceProofLayout string
+
+const (
+	InvoiceProofLayoutThermal InvoiceProofLayout = "thermal" // 5.7cm 感熱紙
+	InvoiceProofLayoutA4      InvoiceProofLayout = "a4"      // A4, 證明聯及交易明細
+)
+
+// 電子發票證明聯文件, BarCode/QRCodeL/QRCodeR 可帶入 ezPay 回傳值, 空值時以 Proof 及 QRKey 產生
+type InvoiceProofDocument struct {
+	Proof         *InvoiceProof
+	Layout        InvoiceProofLayout
+	SellerName    string // 營業人名稱, 列印於證明聯上方
+	SellerAddress string // 營業人地址, 選填
+	QRKey         string // ezPay 後台設定的 QR Code 加密金鑰
+	BarCode       string
+	QRCodeL       string
+	QRCodeR       string
+	Reprint       bool // 補印
+}
+
+type invoiceProofItemView struct {
+	Name   string
+	Count  int
+	Price  int
+	Amount int
+}
+
+type invoiceProofView struct {
+	Layout        InvoiceProofLayout
+	SellerName    string
+	SellerAddress string
+	Title         string
+	Period        string
+	InvoiceNumber string
+	CreateTime    string
+	RandomNum     string
+	TotalAmt      int
+	SellerUBN     string
+	BuyerUBN      string
+	Items         []invoiceProofItemView
+
+	BarcodeSVG template.HTML
+	QRCodeLSVG template.HTML
+	QRCodeRSVG template.HTML
+
+	barcode, qrCodeL, qrCodeR string
+}
+
+func (d InvoiceProofDocument) view() (*invoiceProofView, error) {
+	if d.Proof == nil {
+		return nil, errors.New("missing invoice proof")
+	}
+
+	if err := d.Proof.validate(); err != nil {
+		return nil, err
+	}
+
+	switch d.Layout {
+	case InvoiceProofLayoutThermal, InvoiceProofLayoutA4:
+	default:
+		return nil, fmt.Errorf("invalid invoice proof layout: %s", d.Layout)
+	}
+
+	p := d.Proof
+	barcode := d.BarCode
+	if barcode == "" {
+		var err error
+		if barcode, err = p.BarcodePayload(); err != nil {
+			return nil, err
+		}
+	}
+
+	qrCodeL, qrCodeR := d.QRCodeL, d.QRCodeR
+	if qrCodeL == "" {
+		var err error
+		if qrCodeL, qrCodeR, err = p.QRCodePayloads(d.QRKey); err != nil {
+			return nil, err
+		}
+	}
+
+	location, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		return nil, err
+	}
+	createTime := p.CreateTime.In(location)
+
+	period, err := InvoicePeriodOf(createTime)
+	if err != nil {
+		return nil, err
+	}
+
+	title := "電子發票證明聯"
+	if d.Reprint {
+		title += "補印"
+	}
+
+	v := invoiceProofView{
+		Layout:        d.Layout,
+		SellerName:    d.SellerName,
+		SellerAddress: d.SellerAddress,
+		Title:         title,
+		Period:        period.Label(),
+		InvoiceNumber: p.InvoiceNumber[:2] + "-" + p.InvoiceNumber[2:],
+		CreateTime:    createTime.Format("2006-01-02 15:04:05"),
+		RandomNum:     p.RandomNum,
+		TotalAmt:      p.TotalAmt,
+		SellerUBN:     p.SellerUBN,
+		BuyerUBN:      p.BuyerUBN,
+		barcode:       barcode,
+		qrCodeL:       qrCodeL,
+		qrCodeR:       qrCodeR,
+	}
+
+	for _, item := range p.Items {
+		v.Items = append(v.Items, invoiceProofItemView{
+			Name:   item.Name,
+			Count:  item.Count,
+			Price:  item.Price,
+			Amount: item.Count * item.Price,
+		})
+	}
+
+	svg, err := Code39SVG(barcode, 1, 40)
+	if err != nil {
+		return nil, err
+	}
+	v.BarcodeSVG = template.HTML(svg)
+
+	if svg, err = QRCodeSVG(qrCodeL, 2); err != nil {
+		return nil, err
+	}
+	v.QRCodeLSVG = template.HTML(svg)
+
+	if svg, err = QRCodeSVG(qrCodeR, 2); err != nil {
+		return nil, err
+	}
+	v.QRCodeRSVG = template.HTML(svg)
+
+	return &v, nil
+}
+
+var invoiceProofTemplate = template.Must(template.New("invoice-proof").Parse(`<!DOCTYPE html>
+<html lang="zh-Hant">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} {{.InvoiceNumber}}</title>
+<style>
+body { margin: 0; font-family: sans-serif; color: #000; }
+.page-a4 { width: 21cm; padding: 1.5cm; box-sizing: border-box; }
+.proof { width: 5.7cm; padding: 0.45cm; box-sizing: border-box; }
+.page-a4 .proof { border: 1px dashed #999; }
+.proof .center { text-align: center; }
+.proof .seller { font-size: 12px; }
+.proof .title, .proof .period, .proof .number { font-size: 20px; font-weight: bold; }
+.proof .row { display: flex; justify-content: space-between; font-size: 10px; }
+.proof .barcode svg { width: 100%; height: 0.5cm; }
+.proof .qrcodes { display: flex; justify-content: space-between; }
+.proof .qrcodes svg { width: 2.2cm; height: 2.2cm; }
+.items { width: 100%; border-collapse: collapse; font-size: 10px; margin-top: 0.3cm; }
+.items th, .items td { padding: 2px 0; text-align: right; }
+.items th:first-child, .items td:first-child { text-align: left; }
+.page-a4 .items { font-size: 12px; }
+.page-a4 .items th, .page-a4 .items td { border-bottom: 1px solid #ccc; padding: 4px; }
+</style>
+</head>
+<body>
+<div class="{{if eq .Layout "a4"}}page-a4{{else}}page-thermal{{end}}">
+<div class="proof">
+{{if .SellerName}}<div class="center seller">{{.SellerName}}</div>{{end}}
+<div class="center title">{{.Title}}</div>
+<div class="center period">{{.Period}}</div>
+<div class="center number">{{.InvoiceNumber}}</div>
+<div class="row"><span>{{.CreateTime}}</span>{{if .BuyerUBN}}<span>格式 25</span>{{end}}</div>
+<div class="row"><span>隨機碼 {{.RandomNum}}</span><span>總計 {{.TotalAmt}}</span></div>
+<div class="row"><span>賣方 {{.SellerUBN}}</span>{{if .BuyerUBN}}<span>買方 {{.BuyerUBN}}</span>{{end}}</div>
+<div class="barcode">{{.BarcodeSVG}}</div>
+<div class="qrcodes">{{.QRCodeLSVG}}{{.QRCodeRSVG}}</div>
+{{if .SellerAddress}}<div class="row"><span>{{.SellerAddress}}</span></div>{{end}}
+</div>
+<table class="items">
+<thead><tr><th>品名</th><th>數量</th><th>單價</th><th>金額</th></tr></thead>
+<tbody>
+{{range .Items}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td>{{.Price}}</td><td>{{.Amount}}</td></tr>
+{{end}}</tbody>
+<tfoot><tr><td>總計</td><td></td><td></td><td>{{.TotalAmt}}</td></tr></tfoot>
+</table>
+</div>
+</body>
+</html>
+`))
+
+// 產生 HTML 證明聯, 條碼以內嵌 SVG 呈現
+func (d InvoiceProofDocument) HTML() (string, error) {
+	v, err := d.view()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := invoiceProofTemplate.Execute(&buf, v); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// 證明聯版面尺寸 (mm)
+const (
+	invoiceProofWidth    = 57.0
+	invoiceProofMargin   = 4.5
+	invoiceProofA4Margin = 15.0
+	invoiceProofQRCode   = 21.0
+	invoiceProofItemRow  = 4.0
+)
+
+// 產生 PDF 證明聯, font 為含繁體中文字元的 TrueType 字型檔內容
+func (d InvoiceProofDocument) PDF(font []byte) ([]byte, error) {
+	if len(font) == 0 {
+		return nil, errors.New("missing font")
+	}
+
+	v, err := d.view()
+	if err != nil {
+		return nil, err
+	}
+
+	var pdf *fpdf.Fpdf
+	if v.Layout == InvoiceProofLayoutA4 {
+		pdf = fpdf.New("P", "mm", "A4", "")
+	} else {
+		height := 100 + float64(len(v.Items)+2)*invoiceProofItemRow
+		pdf = fpdf.NewCustom(&fpdf.InitType{
+			UnitStr: "mm",
+			Size:    fpdf.SizeType{Wd: invoiceProofWidth, Ht: height},
+		})
+	}
+	margin := invoiceProofMargin
+	if v.Layout == InvoiceProofLayoutA4 {
+		margin = invoiceProofA4Margin
+	}
+	// 品項列由 drawInvoiceProofItems 自行換頁, 避免自動換頁後座標與 SetXY 不一致
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(false, margin)
+	pdf.AddUTF8FontFromBytes("proof", "", font)
+	pdf.AddPage()
+
+	if err := registerInvoiceProofImages(pdf, v); err != nil {
+		return nil, err
+	}
+
+	x, y := margin, margin
+	y = drawInvoiceProof(pdf, v, x, y)
+
+	itemsWidth := invoiceProofWidth - 2*invoiceProofMargin
+	if v.Layout == InvoiceProofLayoutA4 {
+		itemsWidth = 180
+		y += 5
+	}
+	drawInvoiceProofItems(pdf, v, x, y, itemsWidth)
+
+	if err := pdf.Error(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func registerInvoiceProofImages(pdf *fpdf.Fpdf, v *invoiceProofView) error {
+	images := []struct {
+		name string
+		png  func() ([]byte, error)
+	}{
+		{"barcode", func() ([]byte, error) { return Code39PNG(v.barcode, 2, 60) }},
+		{"qrcode-l", func() ([]byte, error) { return QRCodePNG(v.qrCodeL, 4) }},
+		{"qrcode-r", func() ([]byte, error) { return QRCodePNG(v.qrCodeR, 4) }},
+	}
+
+	for _, image := range images {
+		b, err := image.png()
+		if err != nil {
+			return err
+		}
+		pdf.RegisterImageOptionsReader(image.name, fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(b))
+	}
+	return nil
+}
+
+// 繪製 5.7cm 寬的證明聯, 回傳繪製後的 y 座標
+func drawInvoiceProof(pdf *fpdf.Fpdf, v *invoiceProofView, x, y float64) float64 {
+	width := invoiceProofWidth - 2*invoiceProofMargin
+
+	center := func(text string, size, height float64) {
+		pdf.SetFont("proof", "", size)
+		pdf.SetXY(x, y)
+		pdf.CellFormat(width, height, text, "", 0, "C", false, 0, "")
+		y += height
+	}
+	row := func(left, right string) {
+		pdf.SetFont("proof", "", 8)
+		pdf.SetXY(x, y)
+		pdf.CellFormat(width/2, 4, left, "", 0, "L", false, 0, "")
+		pdf.CellFormat(width/2, 4, right, "", 0, "R", false, 0, "")
+		y += 4
+	}
+
+	if v.SellerName != "" {
+		center(v.SellerName, 10, 5)
+	}
+	center(v.Title, 16, 8)
+	center(v.Period, 16, 8)
+	center(v.InvoiceNumber, 16, 8)
+
+	format := ""
+	buyer := ""
+	if v.BuyerUBN != "" {
+		format = "格式 25"
+		buyer = "買方 " + v.BuyerUBN
+	}
+	row(v.CreateTime, format)
+	row(fmt.Sprintf("隨機碼 %s", v.RandomNum), fmt.Sprintf("總計 %d", v.TotalAmt))
+	row("賣方 "+v.SellerUBN, buyer)
+
+	y += 1
+	pdf.ImageOptions("barcode", x, y, width, 6, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	y += 7
+
+	pdf.ImageOptions("qrcode-l", x, y, invoiceProofQRCode, invoiceProofQRCode, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	pdf.ImageOptions("qrcode-r", x+width-invoiceProofQRCode, y, invoiceProofQRCode, invoiceProofQRCode, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	y += invoiceProofQRCode + 1
+
+	if v.SellerAddress != "" {
+		pdf.SetFont("proof", "", 7)
+		pdf.SetXY(x, y)
+		pdf.MultiCell(width, 3.5, v.SellerAddress, "", "L", false)
+		y = pdf.GetY()
+	}
+
+	return y
+}
+
+// 繪製交易明細, 超過頁面下緣時換頁並重複表頭
+func drawInvoiceProofItems(pdf *fpdf.Fpdf, v *invoiceProofView, x, y, width float64) {
+	columns := []struct {
+		title string
+		ratio float64
+		align string
+	}{
+		{"品名", 0.52, "L"},
+		{"數量", 0.12, "R"},
+		{"單價", 0.16, "R"},
+		{"金額", 0.20, "R"},
+	}
+
+	pdf.SetFont("proof", "", 8)
+	cells := func(values ...string) {
+		pdf.SetXY(x, y)
+		for i, c := range columns {
+			pdf.CellFormat(width*c.ratio, invoiceProofItemRow, values[i], "B", 0, c.align, false, 0, "")
+		}
+		y += invoiceProofItemRow
+	}
+
+	titles := make([]string, len(columns))
+	for i, c := range columns {
+		titles[i] = c.title
+	}
+
+	_, pageHeight := pdf.GetPageSize()
+	_, top, _, bottom := pdf.GetMargins()
+	row := func(values ...string) {
+		if y+invoiceProofItemRow > pageHeight-bottom {
+			pdf.AddPage()
+			y = top
+			cells(titles...)
+		}
+		cells(values...)
+	}
+
+	row(titles...)
+	for _, item := range v.Items {
+		row(item.Name, fmt.Sprint(item.Count), fmt.Sprint(item.Price), fmt.Sprint(item.Amount))
+	}
+	row("總計", "", "", fmt.Sprint(v.TotalAmt))
+}
@@ -0,0 +1,80 @@
+package newebpay
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func testInvoiceProofDocument(t *testing.T, layout InvoiceProofLayout, items int) InvoiceProofDocument {
+	t.Helper()
+
+	proofItems := make([]InvoiceProofItem, items)
+	for i := range proofItems {
+		proofItems[i] = InvoiceProofItem{Name: fmt.Sprintf("item %d", i), Count: 1, Price: 10}
+	}
+	proof := testInvoiceProof(t, proofItems)
+	return InvoiceProofDocument{Proof: &proof, Layout: layout, SellerName: "Seller", QRKey: testQRKey}
+}
+
+func TestInvoiceProofHTML(t *testing.T) {
+	d := testInvoiceProofDocument(t, InvoiceProofLayoutThermal, 2)
+	html, err := d.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html, "AB-11223344") || !strings.Contains(html, "item 1") {
+		t.Errorf("HTML() = %s", html)
+	}
+}
+
+func TestInvoiceProofInvalid(t *testing.T) {
+	// 帶入 ezPay 回傳的條碼時不會經過 BarcodePayload 的檢查, 仍須拒絕過短的發票號碼
+	d := testInvoiceProofDocument(t, InvoiceProofLayoutA4, 1)
+	d.Proof.InvoiceNumber = "A"
+	d.BarCode, d.QRCodeL, d.QRCodeR = "10506AB112233449999", "left", "right"
+
+	if _, err := d.HTML(); err == nil {
+		t.Error("HTML() expected invalid invoice number error")
+	}
+	if _, err := d.PDF([]byte("font")); err == nil {
+		t.Error("PDF() expected invalid invoice number error")
+	}
+}
+
+// 需以 INVOICE_PROOF_FONT 指定 TrueType 字型檔路徑
+func TestInvoiceProofPDFPagination(t *testing.T) {
+	fontPath := os.Getenv("INVOICE_PROOF_FONT")
+	if fontPath == "" {
+		t.Skip("INVOICE_PROOF_FONT not set")
+	}
+	font, err := os.ReadFile(fontPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		layout    InvoiceProofLayout
+		items     int
+		wantPages int
+	}{
+		{InvoiceProofLayoutA4, 3, 1},
+		{InvoiceProofLayoutA4, 150, 3},
+		{InvoiceProofLayoutThermal, 150, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s-%d", tt.layout, tt.items), func(t *testing.T) {
+			b, err := testInvoiceProofDocument(t, tt.layout, tt.items).PDF(font)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages := bytes.Count(b, []byte("/Type /Page")) - bytes.Count(b, []byte("/Type /Pages"))
+			if pages != tt.wantPages {
+				t.Errorf("pages = %d, want %d", pages, tt.wantPages)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package newebpay
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testQRKey = "0123456789abcdef0123456789abcdef"
+
+func testInvoiceProof(t *testing.T, items []InvoiceProofItem) InvoiceProof {
+	t.Helper()
+
+	location, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return InvoiceProof{
+		InvoiceNumber: "AB11223344",
+		RandomNum:     "9999",
+		CreateTime:    time.Date(2016, 5, 20, 10, 0, 0, 0, location),
+		SellerUBN:     "04595257",
+		SalesAmt:      100,
+		TotalAmt:      105,
+		Items:         items,
+	}
+}
+
+func TestBarcodePayload(t *testing.T) {
+	p := testInvoiceProof(t, nil)
+	payload, err := p.BarcodePayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload != "10506AB112233449999" {
+		t.Errorf("BarcodePayload() = %s", payload)
+	}
+
+	// 台灣時間 2016-07-01 00:30 仍為 UTC 6 月 30 日, 期別應以台灣時間計算
+	p.CreateTime = time.Date(2016, 6, 30, 16, 30, 0, 0, time.UTC)
+	if payload, _ := p.BarcodePayload(); payload != "10508AB112233449999" {
+		t.Errorf("BarcodePayload() = %s, want period 10508", payload)
+	}
+
+	p.InvoiceNumber = "AB"
+	if _, err := p.BarcodePayload(); err == nil {
+		t.Error("expected invalid invoice number error")
+	}
+}
+
+func TestQRCodePayloads(t *testing.T) {
+	p := testInvoiceProof(t, []InvoiceProofItem{
+		{Name: "租金", Count: 1, Price: 100},
+		{Name: "備註:A", Count: 2, Price: 0},
+	})
+
+	left, right, err := p.QRCodePayloads(testQRKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := "AB11223344" + "1050520" + "9999" + "00000064" + "00000069" + "00000000" + "04595257"
+	if !strings.HasPrefix(left, header) {
+		t.Fatalf("left = %s, want prefix %s", left, header)
+	}
+
+	encrypted := left[len(header) : len(header)+24]
+	if plaintext := decryptTestQRCode(t, encrypted); plaintext != "AB112233449999" {
+		t.Errorf("encrypted verification = %s", plaintext)
+	}
+
+	if rest := left[len(header)+24:]; rest != ":**********:2:2:1:租金:1:100:備註：A:2:0" {
+		t.Errorf("left items = %s", rest)
+	}
+	if right != "**" {
+		t.Errorf("right = %s", right)
+	}
+}
+
+func TestQRCodePayloadsOverflow(t *testing.T) {
+	items := make([]InvoiceProofItem, 30)
+	for i := range items {
+		items[i] = InvoiceProofItem{Name: fmt.Sprintf("品項%02d", i), Count: 1, Price: 10}
+	}
+	p := testInvoiceProof(t, items)
+	p.BuyerUBN = "12345675"
+
+	left, right, err := p.QRCodePayloads(testQRKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(left) > invoiceQRCodeMaxBytes || len(right) > invoiceQRCodeMaxBytes {
+		t.Errorf("len(left) = %d, len(right) = %d, want <= %d", len(left), len(right), invoiceQRCodeMaxBytes)
+	}
+	if !strings.Contains(left, "1234567504595257") {
+		t.Errorf("left = %s, want buyer UBN", left)
+	}
+
+	fields := strings.Split(left, ":")
+	leftCount := (len(fields) - 5) / 3
+	rightCount := strings.Count(right, ":")/3 + 1
+	if fields[2] != fmt.Sprint(leftCount+rightCount) || fields[3] != "30" {
+		t.Errorf("left counts = %s:%s, items on left %d, right %d", fields[2], fields[3], leftCount, rightCount)
+	}
+	if !strings.HasPrefix(right, "**"+items[leftCount].Name+":1:10") {
+		t.Errorf("right = %s", right)
+	}
+}
+
+func TestQRCodePayloadsInvalid(t *testing.T) {
+	p := testInvoiceProof(t, nil)
+	if _, _, err := p.QRCodePayloads("0123"); err == nil {
+		t.Error("expected invalid QR code key error")
+	}
+
+	p.SellerUBN = "04595258"
+	if _, _, err := p.QRCodePayloads(testQRKey); err == nil {
+		t.Error("expected invalid seller UBN error")
+	}
+
+	p = testInvoiceProof(t, nil)
+	p.RandomNum = ""
+	if _, _, err := p.QRCodePayloads(testQRKey); err == nil {
+		t.Error("expected invalid random number error")
+	}
+}
+
+func decryptTestQRCode(t *testing.T, encrypted string) string {
+	t.Helper()
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := hex.DecodeString(testQRKey)
+	iv, _ := base64.StdEncoding.DecodeString(invoiceQRCodeIv)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, ciphertext)
+
+	unpadded, err := PKCS7Unpadding(decrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(unpadded)
+}
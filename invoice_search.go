@@ -0,0 +1,197 @@
+package newebpay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+type SearchInvoicePostData struct {
+	RespondType     string `json:"RespondType"`     // 回應格式 JSON
+	Version         string `json:"Version"`         // API 版本號 1.3
+	TimeStamp       string `json:"TimeStamp"`       // 時間戳記，Unix 格式
+	SearchType      string `json:"SearchType"`      // 查詢方式 0=發票號碼及隨機碼, 1=訂單編號及發票金額
+	MerchantOrderNo string `json:"MerchantOrderNo"` // 商店訂單編號, SearchType=1 時必填
+	TotalAmt        int    `json:"TotalAmt"`        // 發票金額, SearchType=1 時必填
+	InvoiceNumber   string `json:"InvoiceNumber"`   // 發票號碼, SearchType=0 時必填
+	RandomNum       string `json:"RandomNum"`       // 發票防偽隨機碼, SearchType=0 時必填
+	DisplayFlag     string `json:"DisplayFlag"`     // 空值=回傳查詢結果, 1=導向 ezPay 發票頁面
+}
+
+func (a Api) SearchInvoiceByMerchantOrderNo(merchant *Merchant, merchantOrderNo string, totalAmount int, requestedAt xtime.Time) (*RespInvoiceSearch, error) {
+	if merchantOrderNo == "" || totalAmount <= 0 {
+		return nil, errors.New("Missing merchant order no or total amount")
+	}
+
+	return a.searchInvoice(merchant, SearchInvoicePostData{
+		SearchType:      "1",
+		MerchantOrderNo: merchantOrderNo,
+		TotalAmt:        totalAmount,
+	}, requestedAt)
+}
+
+func (a Api) SearchInvoiceByNumber(merchant *Merchant, invoiceNumber, randomNum string, requestedAt xtime.Time) (*RespInvoiceSearch, error) {
+	if invoiceNumber == "" || randomNum == "" {
+		return nil, errors.New("Missing invoice number or random number")
+	}
+
+	return a.searchInvoice(merchant, SearchInvoicePostData{
+		SearchType:    "0",
+		InvoiceNumber: invoiceNumber,
+		RandomNum:     randomNum,
+	}, requestedAt)
+}
+
+// 查詢發票 (invoice_search) 查無發票資料的回應代碼
+const InvoiceSearchStatusNotFound = "INV90005"
+
+// ezPay 查詢發票回傳查無發票資料
+func (e *InvoiceError) IsNotFound() bool {
+	return e.Tag == "search-invoice" && e.Status == InvoiceSearchStatusNotFound
+}
+
+func (a Api) searchInvoice(merchant *Merchant, postData SearchInvoicePostData, requestedAt xtime.Time) (*RespInvoiceSearch, error) {
+	postData.RespondType = "JSON"
+	postData.Version = "1.3"
+	postData.TimeStamp = strconv.FormatInt(time.Time(requestedAt).Unix(), 10)
+
+	tp, err := postInvoiceForm(a.ApiUrlInvoiceSearch, merchant, postData, "search-invoice")
+	if err != nil {
+		return nil, err
+	}
+
+	payload := RespInvoiceSearch{
+		Status:  tp.Status,
+		Message: tp.Message,
+	}
+	if err := tp.AssertString(&payload.Result); err != nil {
+		return nil, fmt.Errorf("[search-invoice] assert: %v", err)
+	}
+
+	return &payload, nil
+}
+
+type RespInvoiceSearch struct {
+	Status  string              `json:"Status"`
+	Message string              `json:"Message"`
+	Result  ResultInvoiceSearch `json:"Result"`
+}
+
+func (r RespInvoiceSearch) IsSuccess() bool {
+	return r.Status == "SUCCESS"
+}
+
+// 發票狀態
+const (
+	InvoiceStatusIssued = "1" // 開立
+	InvoiceStatusVoided = "2" // 作廢
+)
+
+type ResultInvoiceSearch struct {
+	MerchantID       string     `json:"MerchantID"`
+	InvoiceTransNo   string     `json:"InvoiceTransNo"`   // ezPay 開立序號
+	MerchantOrderNo  string     `json:"MerchantOrderNo"`  // 商店訂單編號
+	InvoiceNumber    string     `json:"InvoiceNumber"`    // 發票號碼
+	RandomNum        string     `json:"RandomNum"`        // 發票防偽隨機碼
+	BuyerName        string     `json:"BuyerName"`        // 買受人名稱
+	BuyerUBN         string     `json:"BuyerUBN"`         // 買受人統一編號
+	BuyerAddress     string     `json:"BuyerAddress"`     // 買受人地址
+	BuyerPhone       string     `json:"BuyerPhone"`       // 買受人電話
+	BuyerEmail       string     `json:"BuyerEmail"`       // 買受人電子信箱
+	InvoiceType      string     `json:"InvoiceType"`      // 發票種類 07=一般稅額, 08=特種稅額
+	Category         string     `json:"Category"`         // 發票類別 B2B, B2C
+	TaxType          string     `json:"TaxType"`          // 課稅別 1=應稅, 2=零稅率, 3=免稅, 9=混合應稅與免稅或零稅率
+	TaxRate          FlexString `json:"TaxRate"`          // 稅率
+	Amt              FlexInt    `json:"Amt"`              // 銷售額合計 (未稅)
+	TaxAmt           FlexInt    `json:"TaxAmt"`           // 稅額
+	TotalAmt         FlexInt    `json:"TotalAmt"`         // 發票金額
+	CarrierType      string     `json:"CarrierType"`      // 載具類別 0=手機條碼, 1=自然人憑證條碼, 2=ezPay 電子發票載具
+	CarrierNum       string     `json:"CarrierNum"`       // 載具編號
+	LoveCode         string     `json:"LoveCode"`         // 愛心碼
+	PrintFlag        string     `json:"PrintFlag"`        // 索取紙本發票 Y/N
+	CreateTime       string     `json:"CreateTime"`       // 開立時間 YYYY-MM-DD HH:mm:ss
+	ItemDetail       string     `json:"ItemDetail"`       // 商品明細 (JSON 字串)
+	InvoiceStatus    string     `json:"InvoiceStatus"`    // 發票狀態 1=開立, 2=作廢
+	CreateStatusTime string     `json:"CreateStatusTime"` // 預約開立日期
+	UploadStatus     string     `json:"UploadStatus"`     // 上傳狀態 0=未上傳, 1=上傳成功, 2=上傳中, 3=上傳失敗
+	CheckCode        string     `json:"CheckCode"`
+	BarCode          string     `json:"BarCode"` // 發票條碼
+	QRcodeL          string     `json:"QRcodeL"` // 發票左方 QR code
+	QRcodeR          string     `json:"QRcodeR"` // 發票右方 QR code
+}
+
+func (r ResultInvoiceSearch) IsVoided() bool {
+	return r.InvoiceStatus == InvoiceStatusVoided
+}
+
+type InvoiceSearchItem struct {
+	ItemNum     FlexInt    `json:"ItemNum"`     // 序號
+	ItemName    string     `json:"ItemName"`    // 商品名稱
+	ItemCount   FlexInt    `json:"ItemCount"`   // 商品數量
+	ItemWord    string     `json:"ItemWord"`    // 商品單位
+	ItemPrice   FlexString `json:"ItemPrice"`   // 商品單價
+	ItemAmount  FlexString `json:"ItemAmount"`  // 商品小計
+	ItemTaxType FlexString `json:"ItemTaxType"` // 商品課稅別, 混合稅率時回傳
+}
+
+func (r ResultInvoiceSearch) Items() ([]InvoiceSearchItem, error) {
+	if r.ItemDetail == "" {
+		return nil, nil
+	}
+
+	var items []InvoiceSearchItem
+	if err := json.Unmarshal([]byte(r.ItemDetail), &items); err != nil {
+		return nil, fmt.Errorf("[search-invoice] ItemDetail: %v", err)
+	}
+
+	return items, nil
+}
+
+// ezPay 回傳的數值欄位可能為字串或數字, 兩者皆可解析
+type FlexString string
+
+func (s *FlexString) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*s = ""
+		return nil
+	}
+
+	var v string
+	if err := json.Unmarshal(data, &v); err == nil {
+		*s = FlexString(v)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*s = FlexString(n.String())
+	return nil
+}
+
+type FlexInt int
+
+func (i *FlexInt) UnmarshalJSON(data []byte) error {
+	var s FlexString
+	if err := s.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	if s == "" {
+		*i = 0
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(string(s), 64)
+	if err != nil {
+		return err
+	}
+	*i = FlexInt(f)
+	return nil
+}
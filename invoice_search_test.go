@@ -0,0 +1,114 @@
+package newebpay
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Loopmaas/xtime"
+)
+
+func TestSearchInvoicePostData(t *testing.T) {
+	ezPay := newFakeEzPay(t, map[string]any{"InvoiceNumber": "AB12345678", "InvoiceStatus": "1"})
+	a := Api{ApiUrlInvoiceSearch: ezPay.URL}
+
+	if _, err := a.SearchInvoiceByMerchantOrderNo(testMerchant, "ORDER1", 1050, xtime.NowUTC()); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"Version": "1.3", "SearchType": "1", "MerchantOrderNo": "ORDER1", "TotalAmt": "1050"} {
+		if got := ezPay.postData.Get(key); got != want {
+			t.Errorf("by order: %s = %q, want %q", key, got, want)
+		}
+	}
+
+	if _, err := a.SearchInvoiceByNumber(testMerchant, "AB12345678", "0142", xtime.NowUTC()); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"SearchType": "0", "InvoiceNumber": "AB12345678", "RandomNum": "0142"} {
+		if got := ezPay.postData.Get(key); got != want {
+			t.Errorf("by number: %s = %q, want %q", key, got, want)
+		}
+	}
+
+	if _, err := a.SearchInvoiceByMerchantOrderNo(testMerchant, "ORDER1", 0, xtime.NowUTC()); err == nil {
+		t.Error("expected error for zero total amount")
+	}
+	if _, err := a.SearchInvoiceByNumber(testMerchant, "AB12345678", "", xtime.NowUTC()); err == nil {
+		t.Error("expected error for missing random number")
+	}
+}
+
+func TestSearchInvoiceResult(t *testing.T) {
+	ezPay := newFakeEzPay(t, map[string]any{
+		"InvoiceNumber": "AB12345678",
+		"InvoiceStatus": InvoiceStatusVoided,
+		"TaxRate":       5,
+		"Amt":           "1000",
+		"TaxAmt":        50,
+		"TotalAmt":      "1050.0",
+		"ItemDetail":    `[{"ItemNum":"1","ItemName":"租金","ItemCount":2,"ItemWord":"日","ItemPrice":525,"ItemAmount":"1050"}]`,
+	})
+	a := Api{ApiUrlInvoiceSearch: ezPay.URL}
+
+	resp, err := a.SearchInvoiceByMerchantOrderNo(testMerchant, "ORDER1", 1050, xtime.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := resp.Result
+	if result.TaxRate != "5" || result.Amt != 1000 || result.TaxAmt != 50 || result.TotalAmt != 1050 || !result.IsVoided() {
+		t.Errorf("Result = %+v", result)
+	}
+
+	items, err := result.Items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].ItemNum != 1 || items[0].ItemCount != 2 || items[0].ItemPrice != "525" || items[0].ItemAmount != "1050" {
+		t.Errorf("Items() = %+v", items)
+	}
+}
+
+func TestSearchInvoiceNotFound(t *testing.T) {
+	ezPay := newFakeEzPay(t, nil)
+	ezPay.status, ezPay.message = InvoiceSearchStatusNotFound, "查無發票資料"
+	a := Api{ApiUrlInvoiceSearch: ezPay.URL}
+
+	_, err := a.SearchInvoiceByNumber(testMerchant, "AB12345678", "0142", xtime.NowUTC())
+	invoiceErr, ok := err.(*InvoiceError)
+	if !ok || !invoiceErr.IsNotFound() {
+		t.Errorf("SearchInvoiceByNumber() error = %v, want not found", err)
+	}
+}
+
+func TestFlexDecoding(t *testing.T) {
+	tests := []struct {
+		data   string
+		str    FlexString
+		number FlexInt
+	}{
+		{`"100"`, "100", 100},
+		{`100`, "100", 100},
+		{`"100.0"`, "100.0", 100},
+		{`""`, "", 0},
+		{`null`, "", 0},
+	}
+	for _, tt := range tests {
+		var s FlexString
+		if err := json.Unmarshal([]byte(tt.data), &s); err != nil || s != tt.str {
+			t.Errorf("FlexString(%s) = %q, %v, want %q", tt.data, s, err, tt.str)
+		}
+		var i FlexInt
+		if err := json.Unmarshal([]byte(tt.data), &i); err != nil || i != tt.number {
+			t.Errorf("FlexInt(%s) = %d, %v, want %d", tt.data, i, err, tt.number)
+		}
+	}
+
+	var i FlexInt
+	if err := json.Unmarshal([]byte(`"abc"`), &i); err == nil {
+		t.Error("expected error for non-numeric FlexInt")
+	}
+	var s FlexString
+	if err := json.Unmarshal([]byte(`{}`), &s); err == nil {
+		t.Error("expected error for object FlexString")
+	}
+}
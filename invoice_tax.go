@@ -0,0 +1,224 @@
+package newebpay
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 發票類別
+const (
+	InvoiceCategoryB2B = "B2B" // 買受人為營業人, 品項金額為未稅
+	InvoiceCategoryB2C = "B2C" // 買受人為個人, 品項金額為含稅
+)
+
+// 課稅別
+const (
+	InvoiceTaxTypeTaxable   = "1" // 應稅
+	InvoiceTaxTypeZeroRated = "2" // 零稅率
+	InvoiceTaxTypeFree      = "3" // 免稅
+	InvoiceTaxTypeMixed     = "9" // 混合應稅與零稅率或免稅
+)
+
+// 通關方式, 零稅率發票必填
+const (
+	CustomsClearanceNone    = "1" // 非經海關出口
+	CustomsClearanceThrough = "2" // 經海關出口
+)
+
+const invoiceTaxRate = 5
+
+// 發票 (或折讓) 的單一品項金額
+type InvoiceLine struct {
+	Item            *InvoiceItem
+	TaxType         string // 課稅別
+	Count           int    // 送出的數量, 通常同 Item.Count, 未稅小計無法整除數量時為 1
+	Price           int    // 送出的單價, B2C 為含稅, B2B 應稅品項為未稅; Price*Count 必等於 Amt
+	Amt             int    // 送出的小計, B2C 為含稅, B2B 應稅品項為未稅
+	TaxExclusiveAmt int    // 未稅小計
+	TaxAmt          int    // 稅額
+}
+
+type InvoiceAmounts struct {
+	Category string
+	Lines    []InvoiceLine
+	TaxType  string
+	TaxRate  string
+	Amt      int  // 銷售額合計 (未稅)
+	AmtSales *int // 銷售額 (應稅, 未稅), 僅混合稅率
+	AmtZero  *int // 銷售額 (零稅率), 僅混合稅率
+	AmtFree  *int // 銷售額 (免稅), 僅混合稅率
+	TaxAmt   int  // 稅額
+	TotalAmt int  // 發票金額 (含稅)
+}
+
+// 計算發票金額: 稅額以應稅品項含稅總額計算後四捨五入, 再將未稅金額依比例分配至各品項 (最大餘數法),
+// 使品項未稅小計加總等於 Amt, 品項稅額加總等於 TaxAmt。品項 Price 皆為含稅金額。
+func CalcInvoiceAmounts(category string, items []*InvoiceItem) (*InvoiceAmounts, error) {
+	if category != InvoiceCategoryB2B && category != InvoiceCategoryB2C {
+		return nil, fmt.Errorf("invalid invoice category: %s", category)
+	}
+
+	if len(items) == 0 {
+		return nil, errors.New("Missing item")
+	}
+
+	sums := map[string]int{}
+	lines := make([]InvoiceLine, len(items))
+	for i, item := range items {
+		if err := item.validate(); err != nil {
+			return nil, fmt.Errorf("item %d: %w", i+1, err)
+		}
+
+		taxType := item.taxType()
+		sums[taxType] += item.Amount()
+		lines[i] = InvoiceLine{
+			Item:            item,
+			TaxType:         taxType,
+			Count:           item.Count,
+			Price:           item.Price,
+			Amt:             item.Amount(),
+			TaxExclusiveAmt: item.Amount(),
+		}
+	}
+
+	taxable, hasTaxable := sums[InvoiceTaxTypeTaxable]
+	zeroRated, hasZeroRated := sums[InvoiceTaxTypeZeroRated]
+	free, hasFree := sums[InvoiceTaxTypeFree]
+
+	if hasZeroRated && hasFree {
+		return nil, errors.New("zero-rated and tax-free items cannot be issued on the same invoice")
+	}
+
+	if taxable+zeroRated+free <= 0 {
+		return nil, errors.New("invoice total amount must be positive")
+	}
+
+	salesAmount, taxAmount := calcTaxExclusiveSalesAmount(taxable)
+	allocateTaxExclusiveAmount(lines, salesAmount)
+
+	if category == InvoiceCategoryB2B {
+		for i := range lines {
+			line := &lines[i]
+			if line.TaxType != InvoiceTaxTypeTaxable {
+				continue
+			}
+			line.Amt = line.TaxExclusiveAmt
+			line.Price, line.Count = line.taxExclusivePrice()
+		}
+	}
+
+	amounts := InvoiceAmounts{
+		Category: category,
+		Lines:    lines,
+		TaxAmt:   taxAmount,
+		TotalAmt: taxable + zeroRated + free,
+	}
+
+	switch {
+	case len(sums) > 1:
+		amounts.TaxType = InvoiceTaxTypeMixed
+		amounts.TaxRate = fmt.Sprint(invoiceTaxRate)
+		amounts.Amt = salesAmount + zeroRated + free
+		amounts.AmtSales = &salesAmount
+		amounts.AmtZero = &zeroRated
+		amounts.AmtFree = &free
+	case hasTaxable:
+		amounts.TaxType = InvoiceTaxTypeTaxable
+		amounts.TaxRate = fmt.Sprint(invoiceTaxRate)
+		amounts.Amt = salesAmount
+	case hasZeroRated:
+		amounts.TaxType = InvoiceTaxTypeZeroRated
+		amounts.TaxRate = "0"
+		amounts.Amt = zeroRated
+	default:
+		amounts.TaxType = InvoiceTaxTypeFree
+		amounts.TaxRate = "0"
+		amounts.Amt = free
+	}
+
+	return &amounts, nil
+}
+
+// 將應稅品項的未稅總額依含稅小計比例分配, 捨去後的餘額依小數部分由大至小逐一補 1 元
+func allocateTaxExclusiveAmount(lines []InvoiceLine, salesAmount int) {
+	taxable := 0
+	var idx []int
+	for i, line := range lines {
+		if line.TaxType == InvoiceTaxTypeTaxable {
+			taxable += line.Amt
+			idx = append(idx, i)
+		}
+	}
+
+	if taxable == 0 {
+		return
+	}
+
+	remainders := make(map[int]int, len(idx))
+	allocated := 0
+	for _, i := range idx {
+		share := salesAmount * lines[i].Amt
+		lines[i].TaxExclusiveAmt = share / taxable
+		remainders[i] = share % taxable
+		allocated += lines[i].TaxExclusiveAmt
+	}
+
+	sort.SliceStable(idx, func(a, b int) bool {
+		return remainders[idx[a]] > remainders[idx[b]]
+	})
+	for k := 0; k < salesAmount-allocated; k++ {
+		lines[idx[k%len(idx)]].TaxExclusiveAmt++
+	}
+
+	for _, i := range idx {
+		lines[i].TaxAmt = lines[i].Amt - lines[i].TaxExclusiveAmt
+	}
+}
+
+// 以未稅小計送出時的單價及數量。ezPay 要求單價乘以數量等於小計, 本套件單價一律以整數送出,
+// 未稅小計無法被數量整除時改以數量 1、單價等於小計送出 (例: 3 日 × 含稅 100 送出 1 日 @ 286)。
+// 這是刻意的取捨: 發票金額正確但品項數量與實際不符, 需要保留數量時由呼叫端寫入品名
+func (l InvoiceLine) taxExclusivePrice() (int, int) {
+	if l.TaxExclusiveAmt%l.Item.Count == 0 {
+		return l.TaxExclusiveAmt / l.Item.Count, l.Item.Count
+	}
+	return l.TaxExclusiveAmt, 1
+}
+
+func (a InvoiceAmounts) hasZeroRated() bool {
+	return a.TaxType == InvoiceTaxTypeZeroRated || (a.AmtZero != nil && *a.AmtZero > 0)
+}
+
+func (a InvoiceAmounts) itemTaxTypes() string {
+	taxTypes := make([]string, len(a.Lines))
+	for i, line := range a.Lines {
+		taxTypes[i] = line.TaxType
+	}
+	return strings.Join(taxTypes, "|")
+}
+
+// ezPay 以 "|" 分隔多個品項, 品名及單位不可包含 "|"
+func (ii InvoiceItem) validate() error {
+	switch {
+	case strings.TrimSpace(ii.Name) == "":
+		return errors.New("missing item name")
+	case strings.TrimSpace(ii.Unit) == "":
+		return errors.New("missing item unit")
+	case strings.Contains(ii.Name, "|") || strings.Contains(ii.Unit, "|"):
+		return errors.New("item name and unit cannot contain \"|\"")
+	case ii.Count <= 0:
+		return fmt.Errorf("invalid item count: %d", ii.Count)
+	case ii.Price < 0:
+		return fmt.Errorf("invalid item price: %d", ii.Price)
+	}
+
+	switch ii.taxType() {
+	case InvoiceTaxTypeTaxable, InvoiceTaxTypeZeroRated, InvoiceTaxTypeFree:
+	default:
+		return fmt.Errorf("invalid item tax type: %s", ii.TaxType)
+	}
+
+	return nil
+}
@@ -0,0 +1,379 @@
+package newebpay
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Loopmaas/xtime"
+)
+
+func checkLineTotals(t *testing.T, amounts *InvoiceAmounts) {
+	t.Helper()
+
+	taxExclusive, tax := 0, 0
+	for i, line := range amounts.Lines {
+		if line.Price*line.Count != line.Amt {
+			t.Errorf("line %d: Price %d * Count %d != Amt %d", i, line.Price, line.Count, line.Amt)
+		}
+		if line.TaxExclusiveAmt+line.TaxAmt != line.Item.Amount() {
+			t.Errorf("line %d: TaxExclusiveAmt %d + TaxAmt %d != %d", i, line.TaxExclusiveAmt, line.TaxAmt, line.Item.Amount())
+		}
+		if line.TaxType == InvoiceTaxTypeTaxable {
+			taxExclusive += line.TaxExclusiveAmt
+			tax += line.TaxAmt
+		}
+	}
+
+	sales := amounts.Amt
+	if amounts.AmtSales != nil {
+		sales = *amounts.AmtSales
+	}
+	if amounts.TaxType != InvoiceTaxTypeZeroRated && amounts.TaxType != InvoiceTaxTypeFree && taxExclusive != sales {
+		t.Errorf("sum of TaxExclusiveAmt %d != sales amount %d", taxExclusive, sales)
+	}
+	if tax != amounts.TaxAmt {
+		t.Errorf("sum of line TaxAmt %d != TaxAmt %d", tax, amounts.TaxAmt)
+	}
+}
+
+func TestCalcInvoiceAmountsB2B(t *testing.T) {
+	tests := []struct {
+		name       string
+		items      []*InvoiceItem
+		wantAmt    int
+		wantTax    int
+		wantCounts []int
+		wantPrices []int
+	}{
+		{
+			name:       "divisible",
+			items:      []*InvoiceItem{{Name: "租金", Count: 2, Unit: "日", Price: 1050}},
+			wantAmt:    2000,
+			wantTax:    100,
+			wantCounts: []int{2},
+			wantPrices: []int{1000},
+		},
+		{
+			name:       "remainder issued as single line",
+			items:      []*InvoiceItem{{Name: "租金", Count: 3, Unit: "日", Price: 100}},
+			wantAmt:    286,
+			wantTax:    14,
+			wantCounts: []int{1},
+			wantPrices: []int{286},
+		},
+		{
+			name: "multiple lines",
+			items: []*InvoiceItem{
+				{Name: "租金", Count: 3, Unit: "日", Price: 333},
+				{Name: "清潔費", Count: 1, Unit: "次", Price: 200},
+			},
+			wantAmt:    1142,
+			wantTax:    57,
+			wantCounts: []int{1, 1},
+			wantPrices: []int{952, 190},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amounts, err := CalcInvoiceAmounts(InvoiceCategoryB2B, tt.items)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if amounts.Amt != tt.wantAmt || amounts.TaxAmt != tt.wantTax {
+				t.Errorf("Amt = %d, TaxAmt = %d, want %d, %d", amounts.Amt, amounts.TaxAmt, tt.wantAmt, tt.wantTax)
+			}
+			for i, line := range amounts.Lines {
+				if line.Count != tt.wantCounts[i] || line.Price != tt.wantPrices[i] {
+					t.Errorf("line %d: Count = %d, Price = %d, want %d, %d", i, line.Count, line.Price, tt.wantCounts[i], tt.wantPrices[i])
+				}
+			}
+			checkLineTotals(t, amounts)
+		})
+	}
+}
+
+func TestIssueInvoiceB2BItemPrices(t *testing.T) {
+	ezPay := newFakeEzPay(t, ResultInvoiceIssue{InvoiceNumber: "AB12345678"})
+	a := Api{ApiUrlInvoiceIssue: ezPay.URL}
+
+	buyer := B2BBuyer{Name: "某某有限公司", UBN: "04595257", Email: "a@b.com"}
+	items := []*InvoiceItem{{Name: "租金", Count: 3, Unit: "日", Price: 100}}
+	if _, err := a.IssueInvoice(testMerchant, buyer, "ORDER1", items, xtime.NowUTC()); err != nil {
+		t.Fatal(err)
+	}
+
+	checkPostedItems(t, ezPay, "286", "1", "286")
+}
+
+func TestMemoInvoiceItemPrices(t *testing.T) {
+	for _, category := range []string{InvoiceCategoryB2C, InvoiceCategoryB2B} {
+		t.Run(category, func(t *testing.T) {
+			ezPay := newFakeEzPay(t, ResultInvoiceMemo{AllowanceNo: "A1"})
+			a := Api{ApiUrlInvoiceMemo: ezPay.URL}
+
+			items := []*InvoiceItem{{Name: "租金", Count: 3, Unit: "日", Price: 100}}
+			if _, err := a.MemoInvoiceWithOptions(testMerchant, "王小明", "a@b.com", "AB12345678", "ORDER1", items, xtime.NowUTC(),
+				MemoInvoiceOptions{Category: category}); err != nil {
+				t.Fatal(err)
+			}
+
+			checkPostedItems(t, ezPay, "286", "1", "286")
+			if taxAmt := ezPay.postData.Get("ItemTaxAmt"); taxAmt != "14" {
+				t.Errorf("ItemTaxAmt = %s, want 14", taxAmt)
+			}
+		})
+	}
+}
+
+func checkPostedItems(t *testing.T, ezPay *fakeEzPay, wantPrice, wantCount, wantAmt string) {
+	t.Helper()
+
+	prices := strings.Split(ezPay.postData.Get("ItemPrice"), "|")
+	counts := strings.Split(ezPay.postData.Get("ItemCount"), "|")
+	amts := strings.Split(ezPay.postData.Get("ItemAmt"), "|")
+	for i := range amts {
+		price, _ := strconv.Atoi(prices[i])
+		count, _ := strconv.Atoi(counts[i])
+		amt, _ := strconv.Atoi(amts[i])
+		if price*count != amt {
+			t.Errorf("item %d: ItemPrice %d * ItemCount %d != ItemAmt %d", i, price, count, amt)
+		}
+	}
+
+	if prices[0] != wantPrice || counts[0] != wantCount || amts[0] != wantAmt {
+		t.Errorf("ItemPrice = %v, ItemCount = %v, ItemAmt = %v", prices, counts, amts)
+	}
+}
+
+func TestCalcInvoiceAmounts(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name     string
+		items    []*InvoiceItem
+		want     InvoiceAmounts
+		wantSale *int
+		wantZero *int
+		wantFree *int
+	}{
+		{
+			name:  "taxable",
+			items: []*InvoiceItem{{Name: "租金", Count: 1, Unit: "次", Price: 1050}},
+			want:  InvoiceAmounts{TaxType: InvoiceTaxTypeTaxable, TaxRate: "5", Amt: 1000, TaxAmt: 50, TotalAmt: 1050},
+		},
+		{
+			name:  "zero rated",
+			items: []*InvoiceItem{{Name: "租金", Count: 1, Unit: "次", Price: 500, TaxType: InvoiceTaxTypeZeroRated}},
+			want:  InvoiceAmounts{TaxType: InvoiceTaxTypeZeroRated, TaxRate: "0", Amt: 500, TotalAmt: 500},
+		},
+		{
+			name:  "tax free",
+			items: []*InvoiceItem{{Name: "租金", Count: 2, Unit: "次", Price: 100, TaxType: InvoiceTaxTypeFree}},
+			want:  InvoiceAmounts{TaxType: InvoiceTaxTypeFree, TaxRate: "0", Amt: 200, TotalAmt: 200},
+		},
+		{
+			name: "mixed",
+			items: []*InvoiceItem{
+				{Name: "租金", Count: 1, Unit: "次", Price: 1050},
+				{Name: "保險", Count: 1, Unit: "次", Price: 500, TaxType: InvoiceTaxTypeZeroRated},
+			},
+			want:     InvoiceAmounts{TaxType: InvoiceTaxTypeMixed, TaxRate: "5", Amt: 1500, TaxAmt: 50, TotalAmt: 1550},
+			wantSale: intPtr(1000),
+			wantZero: intPtr(500),
+			wantFree: intPtr(0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amounts, err := CalcInvoiceAmounts(InvoiceCategoryB2C, tt.items)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if amounts.TaxType != tt.want.TaxType || amounts.TaxRate != tt.want.TaxRate || amounts.Amt != tt.want.Amt ||
+				amounts.TaxAmt != tt.want.TaxAmt || amounts.TotalAmt != tt.want.TotalAmt {
+				t.Errorf("CalcInvoiceAmounts() = %+v, want %+v", amounts, tt.want)
+			}
+			for _, p := range []struct {
+				name      string
+				got, want *int
+			}{{"AmtSales", amounts.AmtSales, tt.wantSale}, {"AmtZero", amounts.AmtZero, tt.wantZero}, {"AmtFree", amounts.AmtFree, tt.wantFree}} {
+				if (p.got == nil) != (p.want == nil) || (p.got != nil && *p.got != *p.want) {
+					t.Errorf("%s = %v, want %v", p.name, p.got, p.want)
+				}
+			}
+			checkLineTotals(t, amounts)
+		})
+	}
+}
+
+func TestCalcInvoiceAmountsInvalid(t *testing.T) {
+	item := func(price int, taxType string) *InvoiceItem {
+		return &InvoiceItem{Name: "租金", Count: 1, Unit: "次", Price: price, TaxType: taxType}
+	}
+
+	tests := []struct {
+		name     string
+		category string
+		items    []*InvoiceItem
+	}{
+		{"invalid category", "B2X", []*InvoiceItem{item(100, "")}},
+		{"missing item", InvoiceCategoryB2C, nil},
+		{"zero rated with tax free", InvoiceCategoryB2C, []*InvoiceItem{item(100, InvoiceTaxTypeZeroRated), item(100, InvoiceTaxTypeFree)}},
+		{"zero total", InvoiceCategoryB2C, []*InvoiceItem{item(0, "")}},
+		{"invalid tax type", InvoiceCategoryB2C, []*InvoiceItem{item(100, "4")}},
+		{"name contains separator", InvoiceCategoryB2C, []*InvoiceItem{{Name: "租|金", Count: 1, Unit: "次", Price: 100}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CalcInvoiceAmounts(tt.category, tt.items); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestAllocateTaxExclusiveAmount(t *testing.T) {
+	tests := []struct {
+		name             string
+		lines            []InvoiceLine
+		salesAmount      int
+		wantTaxExclusive []int
+		wantTax          []int
+	}{
+		{
+			name: "remainder to first largest fraction",
+			lines: []InvoiceLine{
+				{TaxType: InvoiceTaxTypeTaxable, Amt: 100},
+				{TaxType: InvoiceTaxTypeTaxable, Amt: 100},
+				{TaxType: InvoiceTaxTypeTaxable, Amt: 100},
+			},
+			salesAmount:      286,
+			wantTaxExclusive: []int{96, 95, 95},
+			wantTax:          []int{4, 5, 5},
+		},
+		{
+			name: "remainder to largest fraction",
+			lines: []InvoiceLine{
+				{TaxType: InvoiceTaxTypeTaxable, Amt: 999},
+				{TaxType: InvoiceTaxTypeTaxable, Amt: 200},
+			},
+			salesAmount:      1142,
+			wantTaxExclusive: []int{952, 190},
+			wantTax:          []int{47, 10},
+		},
+		{
+			name: "non-taxable lines untouched",
+			lines: []InvoiceLine{
+				{TaxType: InvoiceTaxTypeTaxable, Amt: 105, TaxExclusiveAmt: 105},
+				{TaxType: InvoiceTaxTypeFree, Amt: 50, TaxExclusiveAmt: 50},
+			},
+			salesAmount:      100,
+			wantTaxExclusive: []int{100, 50},
+			wantTax:          []int{5, 0},
+		},
+		{
+			name:             "no taxable lines",
+			lines:            []InvoiceLine{{TaxType: InvoiceTaxTypeZeroRated, Amt: 50, TaxExclusiveAmt: 50}},
+			salesAmount:      0,
+			wantTaxExclusive: []int{50},
+			wantTax:          []int{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocateTaxExclusiveAmount(tt.lines, tt.salesAmount)
+			for i, line := range tt.lines {
+				if line.TaxExclusiveAmt != tt.wantTaxExclusive[i] || line.TaxAmt != tt.wantTax[i] {
+					t.Errorf("line %d: TaxExclusiveAmt = %d, TaxAmt = %d, want %d, %d",
+						i, line.TaxExclusiveAmt, line.TaxAmt, tt.wantTaxExclusive[i], tt.wantTax[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIssueInvoiceMixedTaxPostData(t *testing.T) {
+	ezPay := newFakeEzPay(t, ResultInvoiceIssue{InvoiceNumber: "AB12345678"})
+	a := Api{ApiUrlInvoiceIssue: ezPay.URL}
+
+	buyer := EzPayCarrierBuyer{Name: "王小明", Email: "a@b.com"}
+	items := []*InvoiceItem{
+		{Name: "租金", Count: 1, Unit: "次", Price: 1050},
+		{Name: "保險", Count: 1, Unit: "次", Price: 500, TaxType: InvoiceTaxTypeZeroRated},
+	}
+	if _, err := a.IssueInvoiceWithOptions(testMerchant, buyer, "ORDER1", items, xtime.NowUTC(),
+		InvoiceIssueOptions{CustomsClearance: CustomsClearanceNone}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"TaxType":          InvoiceTaxTypeMixed,
+		"TaxRate":          "5",
+		"Amt":              "1500",
+		"AmtSales":         "1000",
+		"AmtZero":          "500",
+		"AmtFree":          "0",
+		"TaxAmt":           "50",
+		"TotalAmt":         "1550",
+		"ItemTaxType":      "1|2",
+		"CustomsClearance": CustomsClearanceNone,
+	}
+	for key, value := range want {
+		if got := ezPay.postData.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestIssueInvoiceCustomsClearance(t *testing.T) {
+	ezPay := newFakeEzPay(t, ResultInvoiceIssue{InvoiceNumber: "AB12345678"})
+	a := Api{ApiUrlInvoiceIssue: ezPay.URL}
+
+	buyer := EzPayCarrierBuyer{Name: "王小明", Email: "a@b.com"}
+	zeroRated := []*InvoiceItem{{Name: "保險", Count: 1, Unit: "次", Price: 500, TaxType: InvoiceTaxTypeZeroRated}}
+	for _, customsClearance := range []string{"", "3"} {
+		if _, err := a.IssueInvoiceWithOptions(testMerchant, buyer, "ORDER1", zeroRated, xtime.NowUTC(),
+			InvoiceIssueOptions{CustomsClearance: customsClearance}); err == nil {
+			t.Errorf("CustomsClearance %q: expected error for zero-rated item", customsClearance)
+		}
+	}
+
+	taxable := []*InvoiceItem{{Name: "租金", Count: 1, Unit: "次", Price: 1050}}
+	if _, err := a.IssueInvoice(testMerchant, buyer, "ORDER1", taxable, xtime.NowUTC()); err != nil {
+		t.Fatal(err)
+	}
+	if ezPay.postData.Has("CustomsClearance") || ezPay.postData.Has("AmtSales") {
+		t.Errorf("taxable invoice should omit CustomsClearance and AmtSales: %v", ezPay.postData)
+	}
+}
+
+// 未稅小計無法整除數量時, 刻意改以數量 1 送出以維持整數單價
+func TestTaxExclusivePrice(t *testing.T) {
+	tests := []struct {
+		name            string
+		count           int
+		taxExclusiveAmt int
+		wantPrice       int
+		wantCount       int
+	}{
+		{"整除保留數量", 2, 1000, 500, 2},
+		{"無法整除改為 1", 3, 286, 286, 1},
+		{"數量 1", 1, 95, 95, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := InvoiceLine{Item: &InvoiceItem{Count: tt.count}, TaxExclusiveAmt: tt.taxExclusiveAmt}
+			price, count := line.taxExclusivePrice()
+			if price != tt.wantPrice || count != tt.wantCount {
+				t.Errorf("taxExclusivePrice() = %d, %d, want %d, %d", price, count, tt.wantPrice, tt.wantCount)
+			}
+		})
+	}
+}
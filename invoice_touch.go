@@ -0,0 +1,50 @@
+package newebpay
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+type TouchInvoicePostData struct {
+	RespondType     string `json:"RespondType"`     // 回應格式 JSON
+	Version         string `json:"Version"`         // API 版本號 1.0
+	TimeStamp       string `json:"TimeStamp"`       // 時間戳記，Unix 格式
+	InvoiceTransNo  string `json:"InvoiceTransNo"`  // ezPay 開立序號, 開立時回傳的 InvoiceTransNo
+	MerchantOrderNo string `json:"MerchantOrderNo"` // 商店訂單編號
+	TotalAmt        int    `json:"TotalAmt"`        // 發票金額
+}
+
+// 觸發開立以 InvoiceIssueOnTrigger 建立的發票
+func (a Api) TriggerInvoice(merchant *Merchant, invoiceTransNo, merchantOrderNo string, totalAmount int, requestedAt xtime.Time) (*RespInvoiceIssue, error) {
+	if invoiceTransNo == "" || merchantOrderNo == "" {
+		return nil, errors.New("Missing invoice trans no or merchant order no")
+	}
+
+	postData := TouchInvoicePostData{
+		RespondType:     "JSON",
+		Version:         "1.0",
+		TimeStamp:       strconv.FormatInt(time.Time(requestedAt).Unix(), 10),
+		InvoiceTransNo:  invoiceTransNo,
+		MerchantOrderNo: merchantOrderNo,
+		TotalAmt:        totalAmount,
+	}
+
+	tp, err := postInvoiceForm(a.ApiUrlInvoiceTouch, merchant, postData, "touch-invoice")
+	if err != nil {
+		return nil, err
+	}
+
+	payload := RespInvoiceIssue{
+		Status:  tp.Status,
+		Message: tp.Message,
+	}
+	if err := tp.AssertString(&payload.Result); err != nil {
+		return nil, fmt.Errorf("[touch-invoice] assert: %v", err)
+	}
+
+	return &payload, nil
+}
@@ -1,13 +1,9 @@
 package newebpay
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"math"
-	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -21,7 +17,7 @@ type IssueInvoicePostData struct {
 	TimeStamp        string  `json:"TimeStamp"`                  // 時間戳記，Unix 格式
 	TransNum         *string `json:"TransNum,omitempty"`         // 交易序號 (選填)
 	MerchantOrderNo  string  `json:"MerchantOrderNo"`            // 商店訂單編號
-	Status           string  `json:"Status"`                     // 發票狀態 (1 為立即開立)
+	Status           string  `json:"Status"`                     // 開立方式 (0=等待觸發開立, 1=即時開立, 3=預約自動開立)
 	CreateStatusTime *string `json:"CreateStatusTime,omitempty"` // 預計開立日期，預約自動開立發票時才需要帶此參數
 	Category         string  `json:"Category"`                   // 發票類別，B2C
 	BuyerName        string  `json:"BuyerName"`                  // 買受人名稱, 個人姓名
@@ -33,8 +29,8 @@ type IssueInvoicePostData struct {
 	LoveCode         *string `json:"LoveCode,omitempty"`         // 愛心碼 (選填，捐贈發票用)
 	PrintFlag        string  `json:"PrintFlag"`                  // 是否列印紙本發票 (Y 或 [N])
 	KioskPrintFlag   *string `json:"KioskPrintFlag,omitempty"`   // 是否列印於 Kiosk (選填)
-	TaxType          string  `json:"TaxType"`                    // 課稅類別，例如 "1" (應稅)
-	TaxRate          string  `json:"TaxRate"`                    // 稅率，例如 "5"
+	TaxType          string  `json:"TaxType"`                    // 課稅類別 1=應稅, 2=零稅率, 3=免稅, 9=混合
+	TaxRate          string  `json:"TaxRate"`                    // 稅率，應稅及混合為 "5", 零稅率及免稅為 "0"
 	CustomsClearance *string `json:"CustomsClearance,omitempty"` // 通關標記 (零稅率適用，選填)
 	Amt              int     `json:"Amt"`                        // 銷售金額 (未稅)
 	AmtSales         *int    `json:"AmtSales,omitempty"`         // 銷售額應稅 (選填)
@@ -47,7 +43,7 @@ type IssueInvoicePostData struct {
 	ItemUnit         string  `json:"ItemUnit"`                   // 商品單位 (多項以 | 分隔)
 	ItemPrice        string  `json:"ItemPrice"`                  // 商品單價 (多項以 | 分隔), 含稅金額
 	ItemAmt          string  `json:"ItemAmt"`                    // 商品小計 (多項以 | 分隔)
-	ItemTaxType      *string `json:"ItemTaxType,omitempty"`      // 商品稅別 (選填，多項以 | 分隔)
+	ItemTaxType      *string `json:"ItemTaxType,omitempty"`      // 商品稅別 (混合稅率時必填，多項以 | 分隔)
 	// ItemRate         string  `json:"ItemRate"`                   // 商品稅率 (多項以 | 分隔)
 	Comment string `json:"Comment"` // 備註 (選填)
 }
@@ -59,20 +55,80 @@ func calcTaxExclusiveSalesAmount(amount int) (int, int) {
 }
 
 type InvoiceItem struct {
-	Name  string
-	Count int
-	Unit  string
-	Price int // B2C 含稅金額
+	Name    string
+	Count   int
+	Unit    string
+	Price   int    // B2C 含稅金額
+	TaxType string // 課稅別 1=應稅, 2=零稅率, 3=免稅, 空值視為應稅
+}
+
+func (ii InvoiceItem) taxType() string {
+	if ii.TaxType == "" {
+		return InvoiceTaxTypeTaxable
+	}
+	return ii.TaxType
 }
 
 func (ii InvoiceItem) Amount() int {
 	return ii.Count * ii.Price
 }
 
-func (a Api) IssueInvoice(merchant *Merchant,
-	name, email string, mobileCarrierNum *string,
+// 發票開立方式
+const (
+	InvoiceIssueOnTrigger   = "0" // 等待觸發開立, 須再呼叫 TriggerInvoice
+	InvoiceIssueImmediately = "1" // 即時開立
+	InvoiceIssueScheduled   = "3" // 預約自動開立, 於 CreateStatusTime 當日開立
+)
+
+type InvoiceIssueOptions struct {
+	Status           string     // 發票開立方式, 預設即時開立
+	CreateStatusTime *time.Time // 預計開立日期, Status=3 時必填, 須晚於開立請求當日
+	CustomsClearance string     // 通關方式, 含零稅率品項時必填 1=非經海關出口, 2=經海關出口
+}
+
+func (o InvoiceIssueOptions) status() string {
+	if o.Status == "" {
+		return InvoiceIssueImmediately
+	}
+	return o.Status
+}
+
+func (o InvoiceIssueOptions) validate(requestedAt xtime.Time) (*string, error) {
+	switch o.status() {
+	case InvoiceIssueOnTrigger, InvoiceIssueImmediately:
+		return nil, nil
+	case InvoiceIssueScheduled:
+	default:
+		return nil, fmt.Errorf("invalid invoice status: %s", o.Status)
+	}
+
+	if o.CreateStatusTime == nil {
+		return nil, errors.New("Missing create status time")
+	}
+
+	location, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		return nil, err
+	}
+
+	createStatusTime := o.CreateStatusTime.In(location).Format("2006-01-02")
+	if createStatusTime <= time.Time(requestedAt).In(location).Format("2006-01-02") {
+		return nil, fmt.Errorf("create status time must be after request date: %s", createStatusTime)
+	}
+
+	return &createStatusTime, nil
+}
+
+func (a Api) IssueInvoice(merchant *Merchant, buyer InvoiceBuyer,
 	merchantOrderNo string, items []*InvoiceItem, requestedAt xtime.Time,
-	carrier_type *int, invoice_carrie *string,
+) (*RespInvoiceIssue, error) {
+	return a.IssueInvoiceWithOptions(merchant, buyer, merchantOrderNo, items, requestedAt, InvoiceIssueOptions{})
+}
+
+// 等待觸發開立 (Status=0) 或預約自動開立 (Status=3) 的發票
+func (a Api) IssueInvoiceWithOptions(merchant *Merchant, buyer InvoiceBuyer,
+	merchantOrderNo string, items []*InvoiceItem, requestedAt xtime.Time,
+	opts InvoiceIssueOptions,
 ) (*RespInvoiceIssue, error) {
 	fmt.Println("[發票] IssueInvoice")
 	itemLen := len(items)
@@ -80,91 +136,67 @@ func (a Api) IssueInvoice(merchant *Merchant,
 		return nil, errors.New("Missing item")
 	}
 
-	fmt.Printf("[發票] IssueInvoice,url: %s ,merchantOrderNo:%s,name: %s, email: %s, mobileCarrierNum: %v, merchantOrderNo: %s, 發票項目資料:%v, carrier_type: %v, invoice_carrie:%v\n", a.ApiUrlInvoiceIssue, merchantOrderNo, name, email, mobileCarrierNum, merchantOrderNo, items, carrier_type, invoice_carrie)
-
-	totalAmount := 0
-	itemNames := make([]string, itemLen)
-	itemCounts := make([]string, itemLen)
-	itemUnits := make([]string, itemLen)
-	itemPrices := make([]string, itemLen)
-	itemAmts := make([]string, itemLen)
-	itemRates := make([]string, itemLen)
-
-	for i, item := range items {
-		amount := item.Amount()
-		totalAmount += amount
-
-		itemNames[i] = item.Name
-		itemCounts[i] = strconv.Itoa(item.Count)
-		itemUnits[i] = item.Unit
-		itemRates[i] = "1"
-		if carrier_type != nil && *carrier_type == 3 {
-			itemPrices[i] = strconv.Itoa(int(math.Round(float64(item.Price) / 1.05)))
-			itemAmts[i] = strconv.Itoa(int(math.Round(float64(amount) / 1.05)))
-		} else {
-			itemPrices[i] = strconv.Itoa(item.Price)
-			itemAmts[i] = strconv.Itoa(amount)
-		}
-
+	if buyer == nil {
+		return nil, errors.New("Missing buyer")
 	}
 
-	taxExclusiveSalesAmount, taxAmount := calcTaxExclusiveSalesAmount(totalAmount)
+	if err := buyer.Validate(); err != nil {
+		return nil, err
+	}
 
-	printFlag := "Y"
-	carrierType := ""
-	carrierNum := ""
+	createStatusTime, err := opts.validate(requestedAt)
+	if err != nil {
+		return nil, err
+	}
 
-	category := "B2C"
-	buyerNBN := ""
-	if mobileCarrierNum != nil {
-		printFlag = "N"
-		carrierType = "0"
-		carrierNum = *mobileCarrierNum
+	tax, err := CalcInvoiceAmounts(buyer.Category(), items)
+	if err != nil {
+		return nil, err
 	}
 
-	if carrier_type != nil {
-		switch *carrier_type {
-		case 2: // 手機個人載具
-			printFlag = "N"
-			carrierType = "0"
-			carrierNum = *invoice_carrie
-		case 3: // 營業人 B2B 統編
-			category = "B2B"
-			buyerNBN = *invoice_carrie
+	var customsClearance *string
+	if tax.hasZeroRated() {
+		if opts.CustomsClearance != CustomsClearanceNone && opts.CustomsClearance != CustomsClearanceThrough {
+			return nil, fmt.Errorf("invalid customs clearance: %s", opts.CustomsClearance)
 		}
+		customsClearance = &opts.CustomsClearance
 	}
 
-	ItemTaxType := "1"
-	if len(items) > 0 {
-		ItemTaxType = strings.Join(itemRates, "|")
+	fmt.Printf("[發票] IssueInvoice,url: %s ,merchantOrderNo:%s, buyer: %+v, 發票項目資料:%v\n", a.ApiUrlInvoiceIssue, merchantOrderNo, buyer, items)
+
+	itemNames := make([]string, itemLen)
+	itemCounts := make([]string, itemLen)
+	itemUnits := make([]string, itemLen)
+	itemPrices := make([]string, itemLen)
+	itemAmts := make([]string, itemLen)
+
+	for i, line := range tax.Lines {
+		itemNames[i] = line.Item.Name
+		itemCounts[i] = strconv.Itoa(line.Count)
+		itemUnits[i] = line.Item.Unit
+		itemPrices[i] = strconv.Itoa(line.Price)
+		itemAmts[i] = strconv.Itoa(line.Amt)
 	}
+
+	ItemTaxType := tax.itemTaxTypes()
 	postData := IssueInvoicePostData{
 		RespondType:      "JSON",
 		Version:          "1.5",
 		TimeStamp:        strconv.FormatInt(time.Time(requestedAt).Unix(), 10),
 		TransNum:         nil,
 		MerchantOrderNo:  merchantOrderNo,
-		Status:           "1",
-		CreateStatusTime: nil,
-		Category:         category,
-		BuyerName:        name,
-		BuyerUBN:         &buyerNBN,
-		BuyerAddress:     nil,
-		BuyerEmail:       email,
-		CarrierType:      carrierType,
-		CarrierNum:       carrierNum,
-		LoveCode:         nil,
-		PrintFlag:        printFlag,
+		Status:           opts.status(),
+		CreateStatusTime: createStatusTime,
 		KioskPrintFlag:   nil,
-		TaxType:          "1",
-		TaxRate:          "5",
-		CustomsClearance: nil,
-		Amt:              taxExclusiveSalesAmount,
-		AmtSales:         nil,
-		AmtZero:          nil,
-		AmtFree:          nil,
-		TaxAmt:           taxAmount,
-		TotalAmt:         totalAmount,
+		TaxType:          tax.TaxType,
+		TaxRate:          tax.TaxRate,
+		CustomsClearance: customsClearance,
+		Amt:              tax.Amt,
+		AmtSales:         tax.AmtSales,
+		AmtZero:          tax.AmtZero,
+		AmtFree:          tax.AmtFree,
+		TaxAmt:           tax.TaxAmt,
+		TotalAmt:         tax.TotalAmt,
 		ItemName:         strings.Join(itemNames, "|"),
 		ItemCount:        strings.Join(itemCounts, "|"),
 		ItemUnit:         strings.Join(itemUnits, "|"),
@@ -173,48 +205,11 @@ func (a Api) IssueInvoice(merchant *Merchant,
 		ItemTaxType:      &ItemTaxType,
 		Comment:          "",
 	}
+	buyer.apply(&postData)
 
-	jsonData, err := json.Marshal(postData)
+	tp, err := postInvoiceForm(a.ApiUrlInvoiceIssue, merchant, postData, "issue-invoice")
 	if err != nil {
-		fmt.Println("postData json.Marshal error:", err)
-	}
-
-	encData, err := encryptData(postData, merchant.HashKey, merchant.HashIv)
-	if err != nil {
-		return nil, fmt.Errorf("Encryption failed: %v", err)
-	}
-
-	formData := url.Values{
-		"MerchantID_": {merchant.MerchantId},
-		"PostData_":   {encData},
-	}
-
-	fmt.Printf("[發票] IssueInvoice url:%v ,merchantOrderNo:%s, email: %s, mobileCarrierNum: %v, merchantOrderNo: %s, decrypt post data: %s, encrypt data:%s, form data: %v\n", a.ApiUrlInvoiceIssue, merchantOrderNo, email, mobileCarrierNum, merchantOrderNo, jsonData, encData, formData)
-	resp, err := http.PostForm(a.ApiUrlInvoiceIssue, formData)
-	fmt.Printf("[發票] IssueInvoice回傳 ,merchantOrderNo:%s, email: %s, mobileCarrierNum: %v, merchantOrderNo: %s, decrypt post data: %s, encrypt data:%s, form data: %v\n,resp: %v, err: %s\n", merchantOrderNo, email, mobileCarrierNum, merchantOrderNo, jsonData, encData, formData, resp, err)
-	if err != nil {
-		fmt.Println("http.PostForm error:", err)
-		return nil, fmt.Errorf("Failed to submit form: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("[發票] IssueInvoice請求失敗, url:%s ,merchantOrderNo:%s, email: %s, mobileCarrierNum: %v, merchantOrderNo: %s, decrypt post data: %s, encrypt data:%s, form data: %v\n,resp: %v", a.ApiUrlInvoiceIssue, merchantOrderNo, email, mobileCarrierNum, merchantOrderNo, jsonData, encData, formData, resp)
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	receivedData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read received data failed: %v", err)
-	}
-
-	var tp RespPayload
-	if err := json.Unmarshal(receivedData, &tp); err != nil {
-		return nil, fmt.Errorf("[issue-invoice] failed to decode response: %v, received data: %s", err, string(receivedData))
-	}
-	fmt.Printf("[發票] IssueInvoice請求結果 ,url:%s, merchantOrderNo:%s, email: %s, mobileCarrierNum: %v, merchantOrderNo: %s, decrypt post data: %s, encrypt data:%s, form data: %v\n,resp: %v, response:%s\n", a.ApiUrlInvoiceIssue, merchantOrderNo, email, mobileCarrierNum, merchantOrderNo, jsonData, encData, formData, resp, tp)
-	if !tp.IsSuccess() {
-		return nil, fmt.Errorf("[issue-invoice] %s: %s", tp.Status, tp.Message)
+		return nil, err
 	}
 
 	payload := RespInvoiceIssue{
@@ -252,11 +247,64 @@ type ResultInvoiceIssue struct {
 	QRcodeR         *string `json:"QRcodeR,omitempty"`
 }
 
+// 開立 B2C 發票的折讓並立即確認, B2B 發票改用 MemoInvoiceWithOptions
 func (a Api) MemoInvoice(merchant *Merchant,
 	name, email string,
 	invoiceNo, merchantOrderNo string,
 	items []*InvoiceItem,
 	requestedAt xtime.Time,
+) (*RespInvoiceMemo, error) {
+	return a.memoInvoice(merchant, InvoiceCategoryB2C, name, email, invoiceNo, merchantOrderNo, items, AllowanceIssueConfirmed, requestedAt)
+}
+
+// 開立 B2C 發票待確認的折讓, 回傳的折讓單須再以 ConfirmAllowance 確認或 CancelAllowance 取消
+func (a Api) MemoInvoicePending(merchant *Merchant,
+	name, email string,
+	invoiceNo, merchantOrderNo string,
+	items []*InvoiceItem,
+	requestedAt xtime.Time,
+) (*Allowance, error) {
+	return a.MemoInvoiceWithOptions(merchant, name, email, invoiceNo, merchantOrderNo, items, requestedAt, MemoInvoiceOptions{Pending: true})
+}
+
+type MemoInvoiceOptions struct {
+	Category string // 原發票類別 B2B 或 B2C, 空值視為 B2C
+	Pending  bool   // 不立即確認折讓
+}
+
+// 依原發票類別開立折讓, 回傳的折讓單狀態為已確認, 或 Pending 時為待確認
+func (a Api) MemoInvoiceWithOptions(merchant *Merchant,
+	name, email string,
+	invoiceNo, merchantOrderNo string,
+	items []*InvoiceItem,
+	requestedAt xtime.Time,
+	opts MemoInvoiceOptions,
+) (*Allowance, error) {
+	category := opts.Category
+	if category == "" {
+		category = InvoiceCategoryB2C
+	}
+
+	issueStatus, status := AllowanceIssueConfirmed, AllowanceStatusConfirmed
+	if opts.Pending {
+		issueStatus, status = AllowanceIssuePending, AllowanceStatusPending
+	}
+
+	memo, err := a.memoInvoice(merchant, category, name, email, invoiceNo, merchantOrderNo, items, issueStatus, requestedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return memo.Result.Allowance(status), nil
+}
+
+func (a Api) memoInvoice(merchant *Merchant,
+	category string,
+	name, email string,
+	invoiceNo, merchantOrderNo string,
+	items []*InvoiceItem,
+	status string,
+	requestedAt xtime.Time,
 ) (*RespInvoiceMemo, error) {
 	fmt.Println("[newebpay] MemoInvoice")
 	itemLen := len(items)
@@ -264,7 +312,13 @@ func (a Api) MemoInvoice(merchant *Merchant,
 		return nil, errors.New("Missing item")
 	}
 	fmt.Printf("[發票折讓] IssueInvoice , url:%s , merchantOrderNo:%s,name: %s, email: %s,  merchantOrderNo: %s, 發票項目資料:%v", a.ApiUrlInvoiceMemo, merchantOrderNo, name, email, merchantOrderNo, items)
-	totalAmount := 0
+
+	// 折讓品項一律以未稅金額及稅額送出, 依原發票類別計算以與開立時的品項金額一致
+	amounts, err := CalcInvoiceAmounts(category, items)
+	if err != nil {
+		return nil, err
+	}
+
 	itemNames := make([]string, itemLen)
 	itemCounts := make([]string, itemLen)
 	itemUnits := make([]string, itemLen)
@@ -272,20 +326,18 @@ func (a Api) MemoInvoice(merchant *Merchant,
 	itemAmts := make([]string, itemLen)
 	itemTaxAmts := make([]string, itemLen)
 
-	ItemTaxType := "1"
-	for i, item := range items {
-		amount := item.Amount()
-		totalAmount += amount
-
-		taxExclusiveSalesAmount, taxAmount := calcTaxExclusiveSalesAmount(amount)
-
-		itemNames[i] = item.Name
-		itemCounts[i] = strconv.Itoa(item.Count)
-		itemUnits[i] = item.Unit
-		itemPrices[i] = strconv.Itoa(item.Price)
-		itemAmts[i] = strconv.Itoa(taxExclusiveSalesAmount)
-		itemTaxAmts[i] = strconv.Itoa(taxAmount)
-
+	ItemTaxType := InvoiceTaxTypeTaxable
+	if amounts.TaxType == InvoiceTaxTypeMixed {
+		ItemTaxType = amounts.itemTaxTypes()
+	}
+	for i, line := range amounts.Lines {
+		itemNames[i] = line.Item.Name
+		price, count := line.taxExclusivePrice()
+		itemCounts[i] = strconv.Itoa(count)
+		itemUnits[i] = line.Item.Unit
+		itemPrices[i] = strconv.Itoa(price)
+		itemAmts[i] = strconv.Itoa(line.TaxExclusiveAmt)
+		itemTaxAmts[i] = strconv.Itoa(line.TaxAmt)
 	}
 
 	postData := struct {
@@ -299,11 +351,11 @@ func (a Api) MemoInvoice(merchant *Merchant,
 		ItemUnit        string  `json:"ItemUnit"`
 		ItemPrice       string  `json:"ItemPrice"`
 		ItemAmt         string  `json:"ItemAmt"`
-		TaxTypeForMixed *string `json:"TaxTypeForMixed,omitempty"` //  nil
-		ItemTaxAmt      string  `json:"ItemTaxAmt"`                // 0
+		TaxTypeForMixed *string `json:"TaxTypeForMixed,omitempty"` // 混合稅率發票的品項課稅別
+		ItemTaxAmt      string  `json:"ItemTaxAmt"`                // 品項稅額
 		TotalAmt        int     `json:"TotalAmt"`
 		BuyerEmail      string  `json:"BuyerEmail"`
-		Status          string  `json:"Status"` // 1
+		Status          string  `json:"Status"` // 0=不立即確認折讓, 1=立即確認折讓
 	}{
 		RespondType:     "JSON",
 		Version:         "1.3",
@@ -317,40 +369,14 @@ func (a Api) MemoInvoice(merchant *Merchant,
 		ItemAmt:         strings.Join(itemAmts, "|"),
 		TaxTypeForMixed: &ItemTaxType,
 		ItemTaxAmt:      strings.Join(itemTaxAmts, "|"),
-		TotalAmt:        totalAmount,
+		TotalAmt:        amounts.TotalAmt,
 		BuyerEmail:      email,
-		Status:          "1",
+		Status:          status,
 	}
 
-	encData, err := encryptData(postData, merchant.HashKey, merchant.HashIv)
+	tp, err := postInvoiceForm(a.ApiUrlInvoiceMemo, merchant, postData, "memo-invoice")
 	if err != nil {
-		return nil, fmt.Errorf("Encryption failed: %v", err)
-	}
-
-	formData := url.Values{
-		"MerchantID_": {merchant.MerchantId},
-		"PostData_":   {encData},
-	}
-	fmt.Printf("[發票折讓] IssueInvoice , url:%s , merchantOrderNo:%s,name: %s, email: %s,  merchantOrderNo: %s, 發票項目資料:%v, decrypt post data:%v, encrypt post data:%s, formData: %s\n", a.ApiUrlInvoiceMemo, merchantOrderNo, name, email, merchantOrderNo, items, postData, encData, formData)
-	resp, err := http.PostForm(a.ApiUrlInvoiceMemo, formData)
-	fmt.Printf("[發票折讓] IssueInvoice回傳 , url:%s , merchantOrderNo:%s,name: %s, email: %s,  merchantOrderNo: %s, 發票項目資料:%v, decrypt post data:%v, encrypt post data:%s, formData: %s, resp: %v,err: %s\n", a.ApiUrlInvoiceMemo, merchantOrderNo, name, email, merchantOrderNo, items, postData, encData, formData, resp, err)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to submit form: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("[發票折讓] IssueInvoice請求失敗 , url:%s , merchantOrderNo:%s,name: %s, email: %s,  merchantOrderNo: %s, 發票項目資料:%v, decrypt post data:%v, encrypt post data:%s, formData: %s, resp:%v\n", a.ApiUrlInvoiceMemo, merchantOrderNo, name, email, merchantOrderNo, items, postData, encData, formData, resp)
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	var tp RespPayload
-	if err := json.NewDecoder(resp.Body).Decode(&tp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %v", err)
-	}
-	fmt.Printf("[發票折讓] IssueInvoice請求結果 , url:%s , merchantOrderNo:%s,name: %s, email: %s,  merchantOrderNo: %s, 發票項目資料:%v, decrypt post data:%v, encrypt post data:%s, formData: %s, response: %v\n", a.ApiUrlInvoiceMemo, merchantOrderNo, name, email, merchantOrderNo, items, postData, encData, formData, tp)
-	if !tp.IsSuccess() {
-		return nil, fmt.Errorf("[memo-invoice] %s: %s invoiceNo: %s", tp.Status, tp.Message, invoiceNo)
+		return nil, err
 	}
 
 	payload := RespInvoiceMemo{
@@ -383,3 +409,14 @@ type ResultInvoiceMemo struct {
 	RemainAmt       int    `json:"RemainAmt"`
 	CheckCode       string `json:"CheckCode"`
 }
+
+func (r ResultInvoiceMemo) Allowance(status AllowanceStatus) *Allowance {
+	return &Allowance{
+		AllowanceNo:     r.AllowanceNo,
+		InvoiceNumber:   r.InvoiceNumber,
+		MerchantOrderNo: r.MerchantOrderNo,
+		AllowanceAmt:    r.AllowanceAmt,
+		RemainAmt:       r.RemainAmt,
+		Status:          status,
+	}
+}
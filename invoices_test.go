@@ -0,0 +1,171 @@
+package newebpay
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+var testMerchant = NewMerchant("MS123", "12345678901234567890123456789012", "1234567890123456")
+
+// 模擬 ezPay API, 解密收到的 PostData_ 並以 result (JSON 字串) 回應
+type fakeEzPay struct {
+	*httptest.Server
+	status   string
+	message  string
+	result   any
+	postData url.Values
+}
+
+func newFakeEzPay(t *testing.T, result any) *fakeEzPay {
+	t.Helper()
+
+	f := &fakeEzPay{status: "SUCCESS", result: result}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		postData, err := decryptTestPostData(r.PostForm.Get("PostData_"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		f.postData = postData
+
+		resultJSON, _ := json.Marshal(f.result)
+		json.NewEncoder(w).Encode(map[string]any{
+			"Status":  f.status,
+			"Message": f.message,
+			"Result":  string(resultJSON),
+		})
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func decryptTestPostData(encData string) (url.Values, error) {
+	ciphertext, err := hex.DecodeString(encData)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher([]byte(testMerchant.HashKey))
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, []byte(testMerchant.HashIv)).CryptBlocks(decrypted, ciphertext)
+
+	unpadded, err := PKCS7Unpadding(decrypted)
+	if err != nil {
+		return nil, err
+	}
+	return url.ParseQuery(string(unpadded))
+}
+
+func TestMemoInvoicePending(t *testing.T) {
+	ezPay := newFakeEzPay(t, ResultInvoiceMemo{
+		AllowanceNo:     "A113110100001",
+		InvoiceNumber:   "AB12345678",
+		MerchantOrderNo: "ORDER1",
+		AllowanceAmt:    300,
+		RemainAmt:       700,
+	})
+
+	a := Api{ApiUrlInvoiceMemo: ezPay.URL}
+	allowance, err := a.MemoInvoicePending(testMerchant, "王小明", "a@b.com", "AB12345678", "ORDER1",
+		[]*InvoiceItem{{Name: "租金", Count: 1, Unit: "次", Price: 300}}, xtime.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if allowance.Status != AllowanceStatusPending || allowance.AllowanceNo != "A113110100001" || allowance.RemainAmt != 700 {
+		t.Errorf("MemoInvoicePending() = %+v", allowance)
+	}
+	if status := ezPay.postData.Get("Status"); status != AllowanceIssuePending {
+		t.Errorf("Status = %s, want %s", status, AllowanceIssuePending)
+	}
+}
+
+func TestMemoInvoiceError(t *testing.T) {
+	ezPay := newFakeEzPay(t, nil)
+	ezPay.status, ezPay.message = "ALW10001", "折讓金額超過發票可折讓金額"
+
+	a := Api{ApiUrlInvoiceMemo: ezPay.URL}
+	_, err := a.MemoInvoice(testMerchant, "王小明", "a@b.com", "AB12345678", "ORDER1",
+		[]*InvoiceItem{{Name: "租金", Count: 1, Unit: "次", Price: 300}}, xtime.NowUTC())
+
+	invoiceErr, ok := err.(*InvoiceError)
+	if !ok || invoiceErr.Status != "ALW10001" {
+		t.Errorf("MemoInvoice() error = %v, want InvoiceError", err)
+	}
+}
+
+func TestInvoiceIssueOptionsValidate(t *testing.T) {
+	// 2024-03-02 00:30 (Asia/Taipei)
+	requestedAt := xtime.Time(time.Date(2024, 3, 1, 16, 30, 0, 0, time.UTC))
+	at := func(year int, month time.Month, day, hour int) *time.Time {
+		t := time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	tests := []struct {
+		name    string
+		opts    InvoiceIssueOptions
+		want    string
+		wantErr bool
+	}{
+		{"預設即時開立", InvoiceIssueOptions{}, "", false},
+		{"等待觸發開立", InvoiceIssueOptions{Status: InvoiceIssueOnTrigger}, "", false},
+		{"未知開立方式", InvoiceIssueOptions{Status: "2"}, "", true},
+		{"預約未帶日期", InvoiceIssueOptions{Status: InvoiceIssueScheduled}, "", true},
+		{"預約台北當日", InvoiceIssueOptions{Status: InvoiceIssueScheduled, CreateStatusTime: at(2024, 3, 2, 10)}, "", true},
+		{"預約 UTC 隔日但台北當日", InvoiceIssueOptions{Status: InvoiceIssueScheduled, CreateStatusTime: at(2024, 3, 1, 20)}, "", true},
+		{"預約台北隔日", InvoiceIssueOptions{Status: InvoiceIssueScheduled, CreateStatusTime: at(2024, 3, 2, 16)}, "2024-03-03", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.validate(requestedAt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want == "" && got != nil {
+				t.Errorf("validate() = %s, want nil", *got)
+			}
+			if tt.want != "" && (got == nil || *got != tt.want) {
+				t.Errorf("validate() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssueInvoiceScheduled(t *testing.T) {
+	ezPay := newFakeEzPay(t, ResultInvoiceIssue{InvoiceTransNo: "T1"})
+	a := Api{ApiUrlInvoiceIssue: ezPay.URL}
+
+	createStatusTime := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	items := []*InvoiceItem{{Name: "租金", Count: 1, Unit: "次", Price: 300}}
+	if _, err := a.IssueInvoiceWithOptions(testMerchant, EzPayCarrierBuyer{Name: "王小明", Email: "a@b.com"}, "ORDER1", items,
+		xtime.Time(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)),
+		InvoiceIssueOptions{Status: InvoiceIssueScheduled, CreateStatusTime: &createStatusTime}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ezPay.postData.Get("Status"); got != InvoiceIssueScheduled {
+		t.Errorf("Status = %s, want %s", got, InvoiceIssueScheduled)
+	}
+	if got := ezPay.postData.Get("CreateStatusTime"); got != "2024-03-10" {
+		t.Errorf("CreateStatusTime = %s, want 2024-03-10", got)
+	}
+}
@@ -2,16 +2,14 @@ package newebpay
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
-	"strconv"
-	"time"
+	"strings"
 
 	"github.com/Loopmaas/misc"
 	"github.com/Loopmaas/xtime"
 	"github.com/Loopmaas/xuuid"
-	"github.com/mitchellh/mapstructure"
 )
 
 const (
@@ -23,42 +21,18 @@ func NewMerchantId() string {
 	return "LOP" + misc.GenStringWithCharset(NewebpayMerchantIdDigitNum, NewebpayMerchantIdCharset)
 }
 
+// 以預設條件建立企業會員的合作商店: 信用卡 (手續費 0.02, 撥款 3 天), 行業別 7519, 販售類別為服務。
+// 需要其他條件時改用 NewMerchantBuilder。會員或商店資料驗證失敗時回傳 ValidationErrors
 func NewRequestAddMerchant(member *Member, merchantDetail *MerchantDetail,
 	frontendAppRootUrl *url.URL, rentalAgencyId xuuid.UUID, merchantId string, requestedAt xtime.Time,
-) *RequestAddMerchant {
-	r := RequestAddMerchant{
-		Member:         member,
-		MerchantDetail: merchantDetail,
-	}
-
-	r.Version = "1.9"
-	r.TimeStamp = strconv.FormatInt(time.Time(requestedAt).Unix(), 10)
-
-	r.ManagerID = "5;" + r.MemberUnified
-	r.IDCardDate = &r.IncorporationDate
-	r.RepresentCPAdd = &r.CompanyAddress
-	r.RepresentCapitalAmt = &r.CapitalAmount
-	r.RepresentManagerName = &r.RepresentName
-
-	r.MerchantID = merchantId
-	r.MCType = 1
-	r.MerchantWebURL = frontendAppRootUrl.JoinPath("rental-agencies", rentalAgencyId.String()).String()
-	r.MerchantType = 2
-	r.BusinessType = "7519"
-	r.CreditAutoType = 1
-	r.CreditLimit = nil
-	r.PaymentType = "CREDIT:1"
-	r.AgreedFee = "CREDIT:0.02"
-	r.AgreedDay = "CREDIT:3"
-	r.Withdraw = nil
-	r.WithdrawMer = nil
-	r.WithdrawSetting = nil
-
-	return &r
+) (*RequestAddMerchant, error) {
+	return NewMerchantBuilder(merchantId, member, merchantDetail).
+		WebURL(RentalAgencyWebURL(frontendAppRootUrl, rentalAgencyId)).
+		Build(requestedAt)
 }
 
 type Member struct {
-	MemberUnified     string `json:"MemberUnified" binding:"required" example:"12345678 - (企業統編)"`                         // 企業統一編號
+	MemberUnified     string `json:"MemberUnified" binding:"required" example:"12345678 - (企業統編)"`                         // 企業統一編號, 個人會員為身分證字號
 	RepresentName     string `json:"RepresentName" binding:"required" example:"代表人姓名"`                                     // 企業代表人姓名
 	CapitalAmount     string `json:"CapitalAmount" binding:"required" example:"資本額"`                                       // 實收資本額：帶入該企業於工商登記資料相同之資訊
 	IncorporationDate string `json:"IncorporationDate" binding:"required" example:"20241116 (核准設立日期)"`                     // 核准設立日期：帶入該企業於工商登記資料相同之資訊
@@ -98,11 +72,13 @@ type RequestAddMerchant struct {
 
 	// 建立會員所需資料
 	*Member
-	ManagerID            string  `json:"ManagerID"`            // 5;{UBN}
-	IDCardDate           *string `json:"IDCardDate"`           // 法人公司的核准設立日期 YYYYMMDD
-	RepresentCPAdd       *string `json:"RepresentCPAdd"`       // 法人登記地址
-	RepresentCapitalAmt  *string `json:"RepresentCapitalAmt"`  // 法人實收資本額
-	RepresentManagerName *string `json:"RepresentManagerName"` // 法人公司代表人名稱
+	ManagerID            string  `json:"ManagerID"`                      // 5;{UBN} 或 1;{身分證字號}
+	IDCardDate           *string `json:"IDCardDate"`                     // 法人公司的核准設立日期或個人身分證發證日期 YYYYMMDD
+	IDCardPlace          *string `json:"IDCardPlace,omitempty"`          // 個人身分證發證地點
+	IDPic                *int    `json:"IDPic,omitempty"`                // 個人身分證領補換類別 0=初發, 1=補發, 2=換發
+	RepresentCPAdd       *string `json:"RepresentCPAdd,omitempty"`       // 法人登記地址
+	RepresentCapitalAmt  *string `json:"RepresentCapitalAmt,omitempty"`  // 法人實收資本額
+	RepresentManagerName *string `json:"RepresentManagerName,omitempty"` // 法人公司代表人名稱
 
 	// 建立商店所需資料
 	*MerchantDetail
@@ -122,55 +98,39 @@ type RequestAddMerchant struct {
 }
 
 func (a Api) AddMerchant(partnerId, hashKey, hashIv string, data *RequestAddMerchant) (*ResultAddMerchant, error) {
-	encData, err := encryptData(data, hashKey, hashIv)
-	if err != nil {
-		return nil, err
+	if strings.HasPrefix(data.ManagerID, string(MemberTypeCorporate)+";") {
+		if err := ValidateUBN(data.MemberUnified); err != nil {
+			return nil, err
+		}
 	}
 
-	formData := url.Values{
-		"PartnerID_": {partnerId},
-		"PostData_":  {encData},
-	}
-	fmt.Printf("新增商戶, 商戶資料:%s, get url: %s, check value: %s, formData: %s\n", data, a.ApiUrlAddMerchant, formData)
-
-	resp, err := http.PostForm(a.ApiUrlAddMerchant, formData)
-	fmt.Printf("新增商戶結果, 商戶資料:%s, get url: %s, check value: %s, formData: %s, resp: %v, err: %s\n", data, a.ApiUrlAddMerchant, formData, resp, err)
+	payload, err := postPartnerForm(a.ApiUrlAddMerchant, partnerId, hashKey, hashIv, data, "add-merchant")
 	if err != nil {
-		return nil, fmt.Errorf("Failed to submit form: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("新增商戶失敗, 商戶資料:%s, get url: %s, check value: %s, formData: %s, resp: %v, err: %s\n", data, a.ApiUrlAddMerchant, formData, resp, err)
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, err
 	}
 
-	var payload RespAddMerchant
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %v", err)
+	var result ResultAddMerchant
+	if err := json.Unmarshal(payload.Result, &result); err != nil {
+		return nil, fmt.Errorf("[add-merchant] failed to decode result: %w", err)
 	}
-	fmt.Printf("新增商戶請求結果, 商戶資料:%s, get url: %s, check value: %s, formData: %s, resp: %v, response: %v\n", data, a.ApiUrlAddMerchant, formData, resp, payload)
-	if payload.Status != "SUCCESS" {
-		return nil, fmt.Errorf("request failed: [%s]", payload.Status)
+	if result.MerchantID == "" || result.MerchantHashKey == "" || result.MerchantIvKey == "" {
+		return nil, errors.New("[add-merchant] missing merchant credentials in result")
 	}
 
-	var result ResultAddMerchant
-	if err := mapstructure.Decode(payload.Result, &result); err != nil {
-		return nil, fmt.Errorf("failed to decode result: %w", err)
-	}
-	fmt.Printf("新增商戶請求結果解密, 商戶資料:%s, get url: %s, check value: %s, formData: %s, resp: %v, response: %v, result: %v\n", data, a.ApiUrlAddMerchant, formData, resp, payload, result)
 	return &result, nil
 }
 
-type RespAddMerchant struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
-	Result  any    `json:"result"`
-}
+// 同其他合作推廣商 API 的回應
+type RespAddMerchant = RespPartner
 
 type ResultAddMerchant struct {
-	MerchantID      string `json:"MerchantID"`
-	MerchantHashKey string `json:"MerchantHashKey"`
-	MerchantIvKey   string `json:"MerchantIvKey"`
-	MemberType      string `json:"MemberType"`
+	MerchantID      string     `json:"MerchantID"`      // 商店代號
+	MerchantHashKey string     `json:"MerchantHashKey"` // 商店 HashKey
+	MerchantIvKey   string     `json:"MerchantIvKey"`   // 商店 HashIV
+	MemberType      MemberType `json:"MemberType"`      // 會員類別 1=個人, 5=企業
+}
+
+// 新建立合作商店的商店代號及金鑰, 可直接用於交易 API
+func (r ResultAddMerchant) Merchant() *Merchant {
+	return NewMerchant(r.MerchantID, r.MerchantHashKey, r.MerchantIvKey)
 }
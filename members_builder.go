@@ -0,0 +1,330 @@
+package newebpay
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+	"github.com/Loopmaas/xuuid"
+)
+
+// 支付方式代號
+type PaymentMethod string
+
+const (
+	PaymentMethodCredit     PaymentMethod = "CREDIT"     // 信用卡
+	PaymentMethodUnionPay   PaymentMethod = "UNIONPAY"   // 銀聯卡
+	PaymentMethodApplePay   PaymentMethod = "APPLEPAY"   // Apple Pay
+	PaymentMethodGooglePay  PaymentMethod = "ANDROIDPAY" // Google Pay
+	PaymentMethodSamsungPay PaymentMethod = "SAMSUNGPAY" // Samsung Pay
+	PaymentMethodLinePay    PaymentMethod = "LINEPAY"    // LINE Pay
+	PaymentMethodWebATM     PaymentMethod = "WEBATM"     // WebATM
+	PaymentMethodVACC       PaymentMethod = "VACC"       // ATM 轉帳
+	PaymentMethodCVS        PaymentMethod = "CVS"        // 超商代碼繳費
+	PaymentMethodBarcode    PaymentMethod = "BARCODE"    // 超商條碼繳費
+	PaymentMethodTaiwanPay  PaymentMethod = "TAIWANPAY"  // 台灣 Pay
+)
+
+// 支付方式的啟用狀態、手續費率及撥款天數
+type PaymentTerms struct {
+	Enabled    bool
+	Fee        float64 // 交易手續費率, 例如 0.02
+	PayoutDays int     // 撥款天數
+}
+
+// 會員類別, 對應 ManagerID 的證件類別
+type MemberType string
+
+const (
+	MemberTypeIndividual MemberType = "1" // 個人會員, MemberUnified 為身分證字號
+	MemberTypeCorporate  MemberType = "5" // 企業會員, MemberUnified 為統一編號
+)
+
+// 藍新金流回傳的 MemberType 可能為字串或數字
+func (t *MemberType) UnmarshalJSON(data []byte) error {
+	var s FlexString
+	if err := s.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	*t = MemberType(s)
+	return nil
+}
+
+// 個人會員的身分證資料
+type IndividualIdentity struct {
+	IDCardDate  string // 身分證發證日期 YYYYMMDD
+	IDCardPlace string // 身分證發證地點, 例如 北市
+	IDPic       int    // 領補換類別 0=初發, 1=補發, 2=換發
+}
+
+// 提領週期
+type WithdrawCycle string
+
+const (
+	WithdrawCycleDaily   WithdrawCycle = "1" // 每日
+	WithdrawCycleWeekly  WithdrawCycle = "2" // 每週
+	WithdrawCycleMonthly WithdrawCycle = "3" // 每月
+)
+
+type WithdrawSettings struct {
+	Auto          bool          // 是否自動提領
+	AllowMerchant bool          // 是否開放合作商店自行提領
+	Cycle         WithdrawCycle // 自動提領週期
+}
+
+var mccPattern = regexp.MustCompile(`^[0-9]{4}$`)
+
+// 建立 RequestAddMerchant, 未設定的欄位沿用 NewRequestAddMerchant 的預設值
+type MerchantBuilder struct {
+	member         *Member
+	merchantDetail *MerchantDetail
+	merchantId     string
+
+	memberType     MemberType
+	individual     IndividualIdentity
+	webUrl         string
+	mcType         int
+	merchantType   int
+	businessType   string
+	creditAutoType int
+	creditLimit    *int
+	payments       map[PaymentMethod]PaymentTerms
+	withdraw       *WithdrawSettings
+}
+
+func NewMerchantBuilder(merchantId string, member *Member, merchantDetail *MerchantDetail) *MerchantBuilder {
+	return &MerchantBuilder{
+		member:         member,
+		merchantDetail: merchantDetail,
+		merchantId:     merchantId,
+		memberType:     MemberTypeCorporate,
+		mcType:         1,
+		merchantType:   2,
+		businessType:   "7519",
+		creditAutoType: 1,
+		payments: map[PaymentMethod]PaymentTerms{
+			PaymentMethodCredit: {Enabled: true, Fee: 0.02, PayoutDays: 3},
+		},
+	}
+}
+
+// 個人會員, 未呼叫時為企業會員
+func (b *MerchantBuilder) Individual(identity IndividualIdentity) *MerchantBuilder {
+	b.memberType = MemberTypeIndividual
+	b.individual = identity
+	return b
+}
+
+// 合作商店網址
+func (b *MerchantBuilder) WebURL(webUrl string) *MerchantBuilder {
+	b.webUrl = webUrl
+	return b
+}
+
+// 商店類別 1=網路商店, 2=實體商店, 3=網路及實體商店
+func (b *MerchantBuilder) MCType(mcType int) *MerchantBuilder {
+	b.mcType = mcType
+	return b
+}
+
+// 販售類別 1=實體商品, 2=服務, 3=虛擬商品
+func (b *MerchantBuilder) MerchantType(merchantType int) *MerchantBuilder {
+	b.merchantType = merchantType
+	return b
+}
+
+// 行業別 (MCC), 例如 7519 休旅車租賃
+func (b *MerchantBuilder) MCC(mcc string) *MerchantBuilder {
+	b.businessType = mcc
+	return b
+}
+
+// 信用卡是否自動請款
+func (b *MerchantBuilder) CreditAutoCapture(auto bool) *MerchantBuilder {
+	b.creditAutoType = 0
+	if auto {
+		b.creditAutoType = 1
+	}
+	return b
+}
+
+// 信用卡 30 天收款額, 未設定時為合作推廣商與藍新金流約定之預設值
+func (b *MerchantBuilder) CreditLimit(limit int) *MerchantBuilder {
+	b.creditLimit = &limit
+	return b
+}
+
+// 以 payments 取代全部支付方式設定, 包含預設的信用卡
+func (b *MerchantBuilder) Payments(payments map[PaymentMethod]PaymentTerms) *MerchantBuilder {
+	b.payments = make(map[PaymentMethod]PaymentTerms, len(payments))
+	for method, terms := range payments {
+		b.payments[method] = terms
+	}
+	return b
+}
+
+// 新增或覆寫單一支付方式, 預設已啟用信用卡 (手續費 0.02, 撥款 3 天)
+func (b *MerchantBuilder) Payment(method PaymentMethod, terms PaymentTerms) *MerchantBuilder {
+	b.payments[method] = terms
+	return b
+}
+
+func (b *MerchantBuilder) Withdraw(settings WithdrawSettings) *MerchantBuilder {
+	b.withdraw = &settings
+	return b
+}
+
+func (b *MerchantBuilder) validate() error {
+	switch {
+	case b.member == nil:
+		return errors.New("missing member")
+	case b.merchantDetail == nil:
+		return errors.New("missing merchant detail")
+	case b.merchantId == "":
+		return errors.New("missing merchant id")
+	case b.webUrl == "":
+		return errors.New("missing merchant web url")
+	case !mccPattern.MatchString(b.businessType):
+		return fmt.Errorf("invalid MCC: %s", b.businessType)
+	case b.mcType < 1 || b.mcType > 3:
+		return fmt.Errorf("invalid MCType: %d", b.mcType)
+	case b.merchantType < 1 || b.merchantType > 3:
+		return fmt.Errorf("invalid MerchantType: %d", b.merchantType)
+	case len(b.payments) == 0:
+		return errors.New("missing payment type")
+	}
+
+	var fieldErrs ValidationErrors
+	for _, err := range []error{b.member.Validate(b.memberType), b.merchantDetail.Validate()} {
+		if errs, ok := err.(ValidationErrors); ok {
+			fieldErrs = append(fieldErrs, errs...)
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return fieldErrs
+	}
+
+	for method, terms := range b.payments {
+		if method == "" || strings.ContainsAny(string(method), ":|") {
+			return fmt.Errorf("invalid payment method: %s", method)
+		}
+		if terms.Fee < 0 || terms.Fee >= 1 {
+			return fmt.Errorf("invalid %s fee: %v", method, terms.Fee)
+		}
+		if terms.PayoutDays < 0 {
+			return fmt.Errorf("invalid %s payout days: %d", method, terms.PayoutDays)
+		}
+	}
+
+	if b.memberType == MemberTypeIndividual {
+		if b.individual.IDCardDate == "" || b.individual.IDCardPlace == "" {
+			return errors.New("missing ID card date or place")
+		}
+		if b.individual.IDPic < 0 || b.individual.IDPic > 2 {
+			return fmt.Errorf("invalid IDPic: %d", b.individual.IDPic)
+		}
+	}
+
+	if b.withdraw != nil && b.withdraw.Auto {
+		switch b.withdraw.Cycle {
+		case WithdrawCycleDaily, WithdrawCycleWeekly, WithdrawCycleMonthly:
+		default:
+			return fmt.Errorf("invalid withdraw cycle: %s", b.withdraw.Cycle)
+		}
+	}
+
+	return nil
+}
+
+func (b *MerchantBuilder) Build(requestedAt xtime.Time) (*RequestAddMerchant, error) {
+	if err := b.validate(); err != nil {
+		return nil, fmt.Errorf("[add-merchant] %w", err)
+	}
+
+	return b.build(requestedAt), nil
+}
+
+func (b *MerchantBuilder) build(requestedAt xtime.Time) *RequestAddMerchant {
+	r := RequestAddMerchant{
+		Member:         b.member,
+		MerchantDetail: b.merchantDetail,
+	}
+
+	r.Version = "1.9"
+	r.TimeStamp = strconv.FormatInt(time.Time(requestedAt).Unix(), 10)
+
+	r.ManagerID = string(b.memberType) + ";" + r.MemberUnified
+	if b.memberType == MemberTypeIndividual {
+		r.IDCardDate = &b.individual.IDCardDate
+		r.IDCardPlace = &b.individual.IDCardPlace
+		r.IDPic = &b.individual.IDPic
+	} else {
+		r.IDCardDate = &r.IncorporationDate
+		r.RepresentCPAdd = &r.CompanyAddress
+		r.RepresentCapitalAmt = &r.CapitalAmount
+		r.RepresentManagerName = &r.RepresentName
+	}
+
+	r.MerchantID = b.merchantId
+	r.MCType = b.mcType
+	r.MerchantWebURL = b.webUrl
+	r.MerchantType = b.merchantType
+	r.BusinessType = b.businessType
+	r.CreditAutoType = b.creditAutoType
+	r.CreditLimit = b.creditLimit
+
+	methods := make([]string, 0, len(b.payments))
+	for method := range b.payments {
+		methods = append(methods, string(method))
+	}
+	sort.Strings(methods)
+
+	paymentTypes := make([]string, len(methods))
+	fees := make([]string, len(methods))
+	days := make([]string, len(methods))
+	for i, method := range methods {
+		terms := b.payments[PaymentMethod(method)]
+		enabled := "0"
+		if terms.Enabled {
+			enabled = "1"
+		}
+		paymentTypes[i] = method + ":" + enabled
+		fees[i] = method + ":" + strconv.FormatFloat(terms.Fee, 'f', -1, 64)
+		days[i] = method + ":" + strconv.Itoa(terms.PayoutDays)
+	}
+	r.PaymentType = strings.Join(paymentTypes, "|")
+	r.AgreedFee = strings.Join(fees, "|")
+	r.AgreedDay = strings.Join(days, "|")
+
+	if b.withdraw != nil {
+		withdraw := boolFlag(b.withdraw.Auto)
+		withdrawMer := boolFlag(b.withdraw.AllowMerchant)
+		r.Withdraw = &withdraw
+		r.WithdrawMer = &withdrawMer
+		if b.withdraw.Auto {
+			withdrawSetting := string(b.withdraw.Cycle)
+			r.WithdrawSetting = &withdrawSetting
+		}
+	}
+
+	return &r
+}
+
+func boolFlag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// 租賃業者的商店網址 {frontendAppRootUrl}/rental-agencies/{rentalAgencyId}
+func RentalAgencyWebURL(frontendAppRootUrl *url.URL, rentalAgencyId xuuid.UUID) string {
+	return frontendAppRootUrl.JoinPath("rental-agencies", rentalAgencyId.String()).String()
+}
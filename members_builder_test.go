@@ -0,0 +1,95 @@
+package newebpay
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/Loopmaas/xtime"
+	"github.com/Loopmaas/xuuid"
+)
+
+func testMember() *Member {
+	return &Member{
+		MemberUnified:     "04595257",
+		RepresentName:     "王大明",
+		CapitalAmount:     "1000000",
+		IncorporationDate: "20200101",
+		CompanyAddress:    "台北市中山區某某路550號",
+		MemberName:        "某某有限公司",
+		MemberPhone:       "02-22442424",
+		MemberAddress:     "台北市中山區某某路550號",
+		ManagerName:       "王小明",
+		ManagerNameE:      "Xiao Ming,Wang",
+		LoginAccount:      "manager01",
+		ManagerMobile:     "0912345678",
+		ManagerEmail:      "admin@example.com",
+		DisputeMail:       "dispute@example.com",
+	}
+}
+
+func testMerchantDetail() *MerchantDetail {
+	return &MerchantDetail{
+		MerchantEmail:    "cs@example.com",
+		MerchantName:     "某某租車",
+		MerchantNameE:    "Some Rental",
+		MerchantAddrCity: "台北市",
+		MerchantAddrArea: "中山區",
+		MerchantAddrCode: "104",
+		MerchantAddr:     "某某路550號",
+		MerchantEnAddr:   "No. 550, Some Rd., Taipei",
+		NationalE:        "Taiwan",
+		CityE:            "Taipei",
+		MerchantDesc:     "車輛租賃服務",
+		BankCode:         "004",
+		SubBankCode:      "0012",
+		BankAccount:      "123456789012",
+		AccountName:      "某某有限公司",
+	}
+}
+
+func TestNewRequestAddMerchant(t *testing.T) {
+	root, _ := url.Parse("https://app.example.com")
+	agencyId := xuuid.New()
+
+	r, err := NewRequestAddMerchant(testMember(), testMerchantDetail(), root, agencyId, "LOP123", xtime.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ManagerID != "5;04595257" || r.MerchantWebURL != RentalAgencyWebURL(root, agencyId) {
+		t.Errorf("NewRequestAddMerchant() = %+v", r)
+	}
+
+	member := testMember()
+	member.ManagerMobile = "12345"
+	_, err = NewRequestAddMerchant(member, testMerchantDetail(), root, agencyId, "LOP123", xtime.NowUTC())
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("NewRequestAddMerchant() error = %v, want ValidationErrors", err)
+	}
+	if _, ok := errs.Field("ManagerMobile"); !ok {
+		t.Errorf("ValidationErrors = %v", errs)
+	}
+}
+
+func TestMerchantBuilderIndividual(t *testing.T) {
+	r, err := NewMerchantBuilder("LOP123", testIndividualMember(), testMerchantDetail()).
+		Individual(IndividualIdentity{IDCardDate: "20100101", IDCardPlace: "北市", IDPic: 2}).
+		WebURL("https://app.example.com").
+		Build(xtime.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.ManagerID != "1;A123456789" || *r.IDCardDate != "20100101" || *r.IDCardPlace != "北市" || r.RepresentCPAdd != nil {
+		t.Errorf("Build() = %+v", r)
+	}
+
+	_, err = NewMerchantBuilder("LOP123", testIndividualMember(), testMerchantDetail()).
+		WebURL("https://app.example.com").
+		Build(xtime.NowUTC())
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Errorf("Build() error = %v, want ValidationErrors for corporate member with national ID", err)
+	}
+}
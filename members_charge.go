@@ -0,0 +1,125 @@
+package newebpay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+// 扣款指示的資金方向
+type ChargeBalanceType string
+
+const (
+	ChargeBalancePayout ChargeBalanceType = "0" // 正向, 自平台撥款至合作商店
+	ChargeBalanceDeduct ChargeBalanceType = "1" // 負向, 自合作商店撥款金額扣款至平台
+)
+
+// 抽成為 0 元時不送出扣款指示, DeductPlatformCommission 回傳此錯誤
+var ErrZeroCommission = errors.New("[charge-instruct] commission is zero")
+
+// 扣款指示參數, 依藍新金流合作推廣商 API 技術串接手冊「扣款指示」(/API/ChargeInstruct)。
+// FeeType 的代碼以手冊附錄為準, 由呼叫端帶入, 本套件不預設。
+type RequestChargeInstruct struct {
+	Version         string            `json:"Version"`
+	TimeStamp       string            `json:"TimeStamp"`
+	MerchantID      string            `json:"MerchantID"`                // 合作商店代號
+	MerchantOrderNo string            `json:"MerchantOrderNo,omitempty"` // 對應的合作商店訂單編號, 用於對帳
+	Amount          int               `json:"Amount"`                    // 金額
+	FeeType         string            `json:"FeeType"`                   // 費用類別
+	BalanceType     ChargeBalanceType `json:"BalanceType"`               // 0=正向, 1=負向
+	Comment         string            `json:"Comment,omitempty"`         // 備註, 顯示於撥款明細
+}
+
+func NewRequestChargeInstruct(merchantId, feeType string, balanceType ChargeBalanceType, amount int, requestedAt xtime.Time) *RequestChargeInstruct {
+	return &RequestChargeInstruct{
+		Version:     "1.0",
+		TimeStamp:   strconv.FormatInt(time.Time(requestedAt).Unix(), 10),
+		MerchantID:  merchantId,
+		Amount:      amount,
+		FeeType:     feeType,
+		BalanceType: balanceType,
+	}
+}
+
+func (r RequestChargeInstruct) validate() error {
+	switch {
+	case r.MerchantID == "":
+		return errors.New("missing merchant id")
+	case r.Amount <= 0:
+		return fmt.Errorf("invalid amount: %d", r.Amount)
+	case r.FeeType == "":
+		return errors.New("missing fee type")
+	case r.BalanceType != ChargeBalancePayout && r.BalanceType != ChargeBalanceDeduct:
+		return fmt.Errorf("invalid balance type: %s", r.BalanceType)
+	case len([]rune(r.Comment)) > 100:
+		return errors.New("comment must be at most 100 characters")
+	}
+	return nil
+}
+
+// 合作推廣商扣款指示, 於合作商店撥款時增減金額
+func (a Api) ChargeInstruct(partnerId, hashKey, hashIv string, data *RequestChargeInstruct) (*ResultChargeInstruct, error) {
+	if err := data.validate(); err != nil {
+		return nil, fmt.Errorf("[charge-instruct] %w", err)
+	}
+
+	payload, err := postPartnerForm(a.ApiUrlChargeInstruct, partnerId, hashKey, hashIv, data, "charge-instruct")
+	if err != nil {
+		return nil, err
+	}
+
+	var result ResultChargeInstruct
+	if err := json.Unmarshal(payload.Result, &result); err != nil {
+		return nil, fmt.Errorf("[charge-instruct] failed to decode result: %w", err)
+	}
+
+	return &result, nil
+}
+
+// 依費率計算平台抽成, 四捨五入至整數元
+func PlatformCommission(orderAmt int, rate float64) (int, error) {
+	if orderAmt < 0 || rate < 0 || rate >= 1 {
+		return 0, fmt.Errorf("invalid order amount or commission rate: %d, %v", orderAmt, rate)
+	}
+	return int(math.Round(float64(orderAmt) * rate)), nil
+}
+
+// 自合作商店該筆訂單的撥款扣除平台抽成, 抽成為 0 時不送出扣款指示並回傳 ErrZeroCommission。
+// 扣款指示沒有冪等機制, 藍新不會以 MerchantOrderNo 排除重複: 逾時或連線中斷後重試可能重複扣款,
+// 重試前應先於合作推廣商後台或對帳資料確認該訂單是否已有扣款指示編號 (ExeNo)。
+func (a Api) DeductPlatformCommission(partnerId, hashKey, hashIv, merchantId, merchantOrderNo, feeType string,
+	orderAmt int, rate float64, requestedAt xtime.Time,
+) (*ResultChargeInstruct, error) {
+	if merchantOrderNo == "" {
+		return nil, errors.New("[charge-instruct] missing merchant order no")
+	}
+
+	commission, err := PlatformCommission(orderAmt, rate)
+	if err != nil {
+		return nil, fmt.Errorf("[charge-instruct] %w", err)
+	}
+	if commission == 0 {
+		return nil, ErrZeroCommission
+	}
+
+	data := NewRequestChargeInstruct(merchantId, feeType, ChargeBalanceDeduct, commission, requestedAt)
+	data.MerchantOrderNo = merchantOrderNo
+	data.Comment = "平台服務費 " + merchantOrderNo
+
+	return a.ChargeInstruct(partnerId, hashKey, hashIv, data)
+}
+
+type ResultChargeInstruct struct {
+	MerchantID      string            `json:"MerchantID"`
+	MerchantOrderNo string            `json:"MerchantOrderNo"`
+	Amount          FlexInt           `json:"Amount"`
+	FeeType         FlexString        `json:"FeeType"`
+	BalanceType     ChargeBalanceType `json:"BalanceType"`
+	ExeNo           string            `json:"ExeNo"`    // 藍新金流扣款指示編號
+	FundTime        string            `json:"FundTime"` // 預計撥款日 YYYY-MM-DD
+}
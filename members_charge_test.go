@@ -0,0 +1,97 @@
+package newebpay
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Loopmaas/xtime"
+)
+
+// 模擬合作推廣商 API, 以 testMerchant 的金鑰解密收到的 PostData_
+type fakePartner struct {
+	*httptest.Server
+	result   any
+	requests int
+	postData url.Values
+}
+
+func newFakePartner(t *testing.T, result any) *fakePartner {
+	t.Helper()
+
+	f := &fakePartner{result: result}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.requests++
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		postData, err := decryptTestPostData(r.PostForm.Get("PostData_"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		f.postData = postData
+
+		json.NewEncoder(w).Encode(map[string]any{"status": "SUCCESS", "message": "", "result": f.result})
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func TestPlatformCommission(t *testing.T) {
+	tests := []struct {
+		orderAmt int
+		rate     float64
+		want     int
+		wantErr  bool
+	}{
+		{1000, 0.05, 50, false},
+		{999, 0.015, 15, false},
+		{10, 0.01, 0, false},
+		{-1, 0.05, 0, true},
+		{1000, 1, 0, true},
+		{1000, -0.1, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := PlatformCommission(tt.orderAmt, tt.rate)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("PlatformCommission(%d, %v) = %d, %v, want %d", tt.orderAmt, tt.rate, got, err, tt.want)
+		}
+	}
+}
+
+func TestDeductPlatformCommission(t *testing.T) {
+	partner := newFakePartner(t, map[string]any{"MerchantID": "LOP123", "MerchantOrderNo": "ORDER1", "Amount": "50", "FeeType": 1, "BalanceType": "1", "ExeNo": "E1"})
+	a := Api{ApiUrlChargeInstruct: partner.URL}
+
+	result, err := a.DeductPlatformCommission("P1", testMerchant.HashKey, testMerchant.HashIv, "LOP123", "ORDER1", "1", 1000, 0.05, xtime.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExeNo != "E1" || result.Amount != 50 || result.FeeType != "1" {
+		t.Errorf("DeductPlatformCommission() = %+v", result)
+	}
+
+	want := map[string]string{"MerchantID": "LOP123", "MerchantOrderNo": "ORDER1", "Amount": "50", "FeeType": "1", "BalanceType": "1"}
+	for key, value := range want {
+		if got := partner.postData.Get(key); got != value {
+			t.Errorf("%s = %s, want %s", key, got, value)
+		}
+	}
+
+	if _, err := a.DeductPlatformCommission("P1", testMerchant.HashKey, testMerchant.HashIv, "LOP123", "ORDER2", "1", 10, 0.01, xtime.NowUTC()); !errors.Is(err, ErrZeroCommission) {
+		t.Errorf("DeductPlatformCommission() error = %v, want ErrZeroCommission", err)
+	}
+	if _, err := a.DeductPlatformCommission("P1", testMerchant.HashKey, testMerchant.HashIv, "LOP123", "ORDER3", "", 1000, 0.05, xtime.NowUTC()); err == nil {
+		t.Error("expected missing fee type error")
+	}
+	if partner.requests != 1 {
+		t.Errorf("requests = %d, want 1", partner.requests)
+	}
+}
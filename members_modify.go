@@ -0,0 +1,348 @@
+package newebpay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+// 修改合作商店的商店資料, 僅帶入需修改的欄位, 欄位同 MerchantDetail
+type MerchantDetailPatch struct {
+	MerchantEmail    *string `json:"MerchantEmail,omitempty"`    // 客服商店信箱
+	MerchantName     *string `json:"MerchantName,omitempty"`     // 合作商店中文名稱
+	MerchantNameE    *string `json:"MerchantNameE,omitempty"`    // 合作商店英文名稱
+	MerchantAddrCity *string `json:"MerchantAddrCity,omitempty"` // 聯絡地址 - 城市
+	MerchantAddrArea *string `json:"MerchantAddrArea,omitempty"` // 聯絡地址 - 地區
+	MerchantAddrCode *string `json:"MerchantAddrCode,omitempty"` // 聯絡地址 - 郵遞區號
+	MerchantAddr     *string `json:"MerchantAddr,omitempty"`     // 聯絡地址 - 路名及門牌號碼
+	MerchantEnAddr   *string `json:"MerchantEnAddr,omitempty"`   // 商店英文聯絡地址
+	NationalE        *string `json:"NationalE,omitempty"`        // 設立登記營業國家英文名稱
+	CityE            *string `json:"CityE,omitempty"`            // 設立登記營業城市英文名稱
+	MerchantDesc     *string `json:"MerchantDesc,omitempty"`     // 商店簡介
+	BankCode         *string `json:"BankCode,omitempty"`         // 金融機構代碼
+	SubBankCode      *string `json:"SubBankCode,omitempty"`      // 金融機構分行代碼
+	BankAccount      *string `json:"BankAccount,omitempty"`      // 金融機構帳號
+	AccountName      *string `json:"AccountName,omitempty"`      // 金融機構帳戶戶名
+}
+
+// 比較修改前後的商店資料, 僅保留有異動的欄位。撥款帳戶任一欄位異動時, 帳戶四個欄位皆會帶入
+func NewMerchantDetailPatch(from, to *MerchantDetail) *MerchantDetailPatch {
+	changed := func(before, after string) *string {
+		if before == after {
+			return nil
+		}
+		return &after
+	}
+
+	p := MerchantDetailPatch{
+		MerchantEmail:    changed(from.MerchantEmail, to.MerchantEmail),
+		MerchantName:     changed(from.MerchantName, to.MerchantName),
+		MerchantNameE:    changed(from.MerchantNameE, to.MerchantNameE),
+		MerchantAddrCity: changed(from.MerchantAddrCity, to.MerchantAddrCity),
+		MerchantAddrArea: changed(from.MerchantAddrArea, to.MerchantAddrArea),
+		MerchantAddrCode: changed(from.MerchantAddrCode, to.MerchantAddrCode),
+		MerchantAddr:     changed(from.MerchantAddr, to.MerchantAddr),
+		MerchantEnAddr:   changed(from.MerchantEnAddr, to.MerchantEnAddr),
+		NationalE:        changed(from.NationalE, to.NationalE),
+		CityE:            changed(from.CityE, to.CityE),
+		MerchantDesc:     changed(from.MerchantDesc, to.MerchantDesc),
+	}
+
+	if from.BankCode != to.BankCode || from.SubBankCode != to.SubBankCode ||
+		from.BankAccount != to.BankAccount || from.AccountName != to.AccountName {
+		p.SetBankAccount(to.BankCode, to.SubBankCode, to.BankAccount, to.AccountName)
+	}
+
+	return &p
+}
+
+// 修改撥款帳戶, 四個欄位須一併帶入
+func (p *MerchantDetailPatch) SetBankAccount(bankCode, subBankCode, bankAccount, accountName string) {
+	p.BankCode = &bankCode
+	p.SubBankCode = &subBankCode
+	p.BankAccount = &bankAccount
+	p.AccountName = &accountName
+}
+
+func (p MerchantDetailPatch) IsEmpty() bool {
+	return p == MerchantDetailPatch{}
+}
+
+// 撥款帳戶欄位須一併帶入, 格式同建立商店 (MerchantDetail.Validate)
+func (p MerchantDetailPatch) validate() error {
+	bank := []*string{p.BankCode, p.SubBankCode, p.BankAccount, p.AccountName}
+	set := 0
+	for _, v := range bank {
+		if v != nil {
+			set++
+		}
+	}
+
+	if set == 0 {
+		return nil
+	}
+	if set != len(bank) {
+		return errors.New("BankCode, SubBankCode, BankAccount and AccountName must be modified together")
+	}
+
+	var v fieldValidator
+	if v.required("BankCode", *p.BankCode, 3) {
+		v.match("BankCode", *p.BankCode, bankCodePattern, "3-digit bank code")
+	}
+	if v.required("SubBankCode", *p.SubBankCode, 4) {
+		v.match("SubBankCode", *p.SubBankCode, subBankCodePattern, "4-digit branch code")
+	}
+	if v.required("BankAccount", *p.BankAccount, 16) {
+		v.match("BankAccount", *p.BankAccount, bankAccountPattern, "6 to 16 digits")
+	}
+	v.required("AccountName", *p.AccountName, 60)
+
+	return v.err()
+}
+
+type RequestModifyMerchant struct {
+	Version    string `json:"Version"`
+	TimeStamp  string `json:"TimeStamp"`
+	MerchantID string `json:"MerchantID"` // 欲修改的商店代號
+
+	*MerchantDetailPatch
+	CreditLimit *int    `json:"CreditLimit,omitempty"` // 合作商店信用卡 30 天收款額
+	PaymentType *string `json:"PaymentType,omitempty"` // [支付方式代號:啟用狀態] CREDIT:1
+	AgreedFee   *string `json:"AgreedFee,omitempty"`   // 交易手續費 [支付方式代號:費率] CREDIT:0.02
+	AgreedDay   *string `json:"AgreedDay,omitempty"`   // 撥款天數 [支付方式代號:天數] CREDIT:3
+}
+
+func NewRequestModifyMerchant(merchantId string, patch *MerchantDetailPatch, requestedAt xtime.Time) *RequestModifyMerchant {
+	if patch == nil {
+		patch = &MerchantDetailPatch{}
+	}
+
+	return &RequestModifyMerchant{
+		Version:             "1.0",
+		TimeStamp:           strconv.FormatInt(time.Time(requestedAt).Unix(), 10),
+		MerchantID:          merchantId,
+		MerchantDetailPatch: patch,
+	}
+}
+
+func (r RequestModifyMerchant) validate() error {
+	if r.MerchantID == "" {
+		return errors.New("missing merchant id")
+	}
+
+	// 直接建立 RequestModifyMerchant 時 MerchantDetailPatch 可能為 nil
+	var patch MerchantDetailPatch
+	if r.MerchantDetailPatch != nil {
+		patch = *r.MerchantDetailPatch
+	}
+
+	if err := patch.validate(); err != nil {
+		return err
+	}
+
+	if patch.IsEmpty() && r.CreditLimit == nil && r.PaymentType == nil && r.AgreedFee == nil && r.AgreedDay == nil {
+		return errors.New("nothing to modify")
+	}
+
+	for _, agreed := range []*string{r.PaymentType, r.AgreedFee, r.AgreedDay} {
+		if agreed == nil {
+			continue
+		}
+		if _, err := parseAgreedValues(*agreed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 格式為 [支付方式代號:值], 多筆以 "|" 分隔, 例如 CREDIT:0.02|WEBATM:0.01
+func parseAgreedValues(s string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, item := range strings.Split(s, "|") {
+		paymentType, value, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok || paymentType == "" || value == "" {
+			return nil, fmt.Errorf("invalid value: %s", s)
+		}
+		values[strings.ToUpper(paymentType)] = value
+	}
+	return values, nil
+}
+
+// 修改合作商店資料
+func (a Api) ModifyMerchant(partnerId, hashKey, hashIv string, data *RequestModifyMerchant) (*ResultModifyMerchant, error) {
+	if err := data.validate(); err != nil {
+		return nil, fmt.Errorf("[modify-merchant] %w", err)
+	}
+
+	payload, err := postPartnerForm(a.ApiUrlModifyMerchant, partnerId, hashKey, hashIv, data, "modify-merchant")
+	if err != nil {
+		return nil, err
+	}
+
+	var result ResultModifyMerchant
+	if err := json.Unmarshal(payload.Result, &result); err != nil {
+		return nil, fmt.Errorf("[modify-merchant] failed to decode result: %w", err)
+	}
+
+	return &result, nil
+}
+
+type ResultModifyMerchant struct {
+	MerchantID string `json:"MerchantID"`
+}
+
+// 合作推廣商 API 回應, 欄位名稱大小寫不一 (status 或 Status), encoding/json 比對欄位時不分大小寫
+type RespPartner struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// 回應中不可寫入紀錄的欄位, 例如建立商店回傳的商店金鑰
+var partnerSecretFields = []string{"MerchantHashKey", "MerchantIvKey"}
+
+// 遮蔽 result 中的商店金鑰後供紀錄使用, 欄位名稱同 RespPartner 解碼時不分大小寫
+func redactPartnerResponse(receivedData []byte) string {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(receivedData, &payload); err != nil {
+		return string(receivedData)
+	}
+
+	resultKey, ok := findKeyFold(payload, "result")
+	if !ok {
+		return string(receivedData)
+	}
+
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(payload[resultKey], &result); err != nil {
+		return string(receivedData)
+	}
+
+	redacted := false
+	for key := range result {
+		for _, field := range partnerSecretFields {
+			if strings.EqualFold(key, field) {
+				result[key] = json.RawMessage(`"***"`)
+				redacted = true
+			}
+		}
+	}
+	if !redacted {
+		return string(receivedData)
+	}
+
+	payload[resultKey], _ = json.Marshal(result)
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "<redacted>"
+	}
+	return string(b)
+}
+
+// 同 encoding/json 比對欄位的方式, 優先完全相符, 否則不分大小寫
+func findKeyFold(m map[string]json.RawMessage, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+	for key := range m {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// 失敗時 Result 可能為各欄位的錯誤說明, 例如 {"LoginAccount":"帳號已存在"}
+func (r RespPartner) fieldErrors() ValidationErrors {
+	var fields map[string]any
+	if err := json.Unmarshal(r.Result, &fields); err != nil {
+		return nil
+	}
+
+	var errs ValidationErrors
+	for field, v := range fields {
+		if message, ok := v.(string); ok && message != "" {
+			errs = append(errs, FieldError{Field: field, Message: message})
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+// 藍新金流回傳 Status 非 SUCCESS, 例如欄位格式錯誤、商店代號重複
+type PartnerError struct {
+	Tag     string
+	Status  string
+	Message string
+	Fields  ValidationErrors // 欄位錯誤, 回應未提供時為 nil
+}
+
+func (e *PartnerError) Error() string {
+	if len(e.Fields) > 0 {
+		return fmt.Sprintf("[%s] %s: %s (%s)", e.Tag, e.Status, e.Message, e.Fields.Error())
+	}
+	return fmt.Sprintf("[%s] %s: %s", e.Tag, e.Status, e.Message)
+}
+
+// 以 PartnerID_/PostData_ 加密表單呼叫合作推廣商 API, Status 非 SUCCESS 時回傳 *PartnerError
+func postPartnerForm(apiUrl, partnerId, hashKey, hashIv string, data any, tag string) (*RespPartner, error) {
+	return postPartnerFormContext(context.Background(), apiUrl, partnerId, hashKey, hashIv, data, tag)
+}
+
+// 同 postPartnerForm, ctx 取消或逾時時中止請求
+func postPartnerFormContext(ctx context.Context, apiUrl, partnerId, hashKey, hashIv string, data any, tag string) (*RespPartner, error) {
+	encData, err := encryptData(data, hashKey, hashIv)
+	if err != nil {
+		return nil, fmt.Errorf("Encryption failed: %v", err)
+	}
+
+	formData := url.Values{
+		"PartnerID_": {partnerId},
+		"PostData_":  {encData},
+	}
+	fmt.Printf("[%s] url: %s, decrypt post data: %+v, encrypt post data: %s\n", tag, apiUrl, data, formData)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiUrl, strings.NewReader(formData.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to create request: %w", tag, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	fmt.Printf("[%s] 回傳, url: %s, resp: %v, err: %v\n", tag, apiUrl, resp, err)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to submit form: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	receivedData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read received data failed: %v", err)
+	}
+
+	var payload RespPartner
+	if err := json.Unmarshal(receivedData, &payload); err != nil {
+		// 回應內容可能含商店金鑰, 不寫入錯誤訊息
+		return nil, fmt.Errorf("[%s] failed to decode response (%d bytes): %v", tag, len(receivedData), err)
+	}
+	fmt.Printf("[%s] 請求結果, url: %s, response: %s\n", tag, apiUrl, redactPartnerResponse(receivedData))
+	if payload.Status != "SUCCESS" {
+		return nil, &PartnerError{Tag: tag, Status: payload.Status, Message: payload.Message, Fields: payload.fieldErrors()}
+	}
+
+	return &payload, nil
+}
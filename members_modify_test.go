@@ -0,0 +1,123 @@
+package newebpay
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Loopmaas/xtime"
+)
+
+func TestNewMerchantDetailPatch(t *testing.T) {
+	from := testMerchantDetail()
+
+	if patch := NewMerchantDetailPatch(from, testMerchantDetail()); !patch.IsEmpty() {
+		t.Errorf("unchanged detail: patch = %+v, want empty", patch)
+	}
+
+	to := testMerchantDetail()
+	to.MerchantEmail = "support@example.com"
+	patch := NewMerchantDetailPatch(from, to)
+	if patch.MerchantEmail == nil || *patch.MerchantEmail != "support@example.com" {
+		t.Errorf("MerchantEmail = %v", patch.MerchantEmail)
+	}
+	if patch.MerchantName != nil || patch.BankCode != nil {
+		t.Errorf("unchanged fields should be nil: %+v", patch)
+	}
+
+	// 撥款帳戶任一欄位異動時四個欄位皆帶入
+	to = testMerchantDetail()
+	to.BankAccount = "987654321098"
+	patch = NewMerchantDetailPatch(from, to)
+	if patch.BankCode == nil || *patch.BankCode != "004" || patch.SubBankCode == nil || patch.AccountName == nil ||
+		patch.BankAccount == nil || *patch.BankAccount != "987654321098" {
+		t.Errorf("bank account patch = %+v", patch)
+	}
+	if patch.MerchantEmail != nil {
+		t.Errorf("MerchantEmail = %v, want nil", *patch.MerchantEmail)
+	}
+}
+
+func TestRequestModifyMerchantValidate(t *testing.T) {
+	bankPatch := func(bankCode, subBankCode, bankAccount, accountName string) *MerchantDetailPatch {
+		p := &MerchantDetailPatch{}
+		p.SetBankAccount(bankCode, subBankCode, bankAccount, accountName)
+		return p
+	}
+	creditLimit := 100000
+	email := "support@example.com"
+	bankCode := "004"
+	badFee := "CREDIT"
+
+	tests := []struct {
+		name       string
+		req        RequestModifyMerchant
+		wantErr    bool
+		wantFields []string
+	}{
+		{"未指定 patch 僅修改額度", RequestModifyMerchant{MerchantID: "MS1", CreditLimit: &creditLimit}, false, nil},
+		{"未指定 patch 且無修改", RequestModifyMerchant{MerchantID: "MS1"}, true, nil},
+		{"缺商店代號", RequestModifyMerchant{MerchantDetailPatch: &MerchantDetailPatch{MerchantEmail: &email}}, true, nil},
+		{"修改信箱", RequestModifyMerchant{MerchantID: "MS1", MerchantDetailPatch: &MerchantDetailPatch{MerchantEmail: &email}}, false, nil},
+		{"撥款帳戶", RequestModifyMerchant{MerchantID: "MS1", MerchantDetailPatch: bankPatch("004", "0012", "123456789012", "某某有限公司")}, false, nil},
+		{"撥款帳戶未一併帶入", RequestModifyMerchant{MerchantID: "MS1", MerchantDetailPatch: &MerchantDetailPatch{BankCode: &bankCode}}, true, nil},
+		{"撥款帳戶格式錯誤", RequestModifyMerchant{MerchantID: "MS1", MerchantDetailPatch: bankPatch("04", "12", "12345", "")},
+			true, []string{"BankCode", "SubBankCode", "BankAccount", "AccountName"}},
+		{"手續費格式錯誤", RequestModifyMerchant{MerchantID: "MS1", AgreedFee: &badFee}, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantFields == nil {
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != len(tt.wantFields) {
+				t.Fatalf("validate() error = %v, want fields %v", err, tt.wantFields)
+			}
+			for i, field := range tt.wantFields {
+				if errs[i].Field != field {
+					t.Errorf("errs[%d].Field = %s, want %s", i, errs[i].Field, field)
+				}
+			}
+		})
+	}
+}
+
+func TestModifyMerchant(t *testing.T) {
+	partner := newFakePartner(t, ResultModifyMerchant{MerchantID: "MS1"})
+	a := Api{ApiUrlModifyMerchant: partner.URL}
+
+	to := testMerchantDetail()
+	to.MerchantName = "某某租車二店"
+	req := NewRequestModifyMerchant("MS1", NewMerchantDetailPatch(testMerchantDetail(), to), xtime.NowUTC())
+
+	result, err := a.ModifyMerchant("PT1", testMerchant.HashKey, testMerchant.HashIv, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MerchantID != "MS1" {
+		t.Errorf("ModifyMerchant() = %+v", result)
+	}
+
+	if got := partner.postData.Get("MerchantName"); got != "某某租車二店" {
+		t.Errorf("MerchantName = %q", got)
+	}
+	for _, field := range []string{"MerchantEmail", "BankCode", "CreditLimit"} {
+		if partner.postData.Has(field) {
+			t.Errorf("unchanged field %s should not be posted", field)
+		}
+	}
+
+	partner.requests = 0
+	if _, err := a.ModifyMerchant("PT1", testMerchant.HashKey, testMerchant.HashIv, &RequestModifyMerchant{MerchantID: "MS1"}); err == nil {
+		t.Error("expected error for empty modification")
+	}
+	if partner.requests != 0 {
+		t.Error("invalid request should not be sent")
+	}
+}
@@ -0,0 +1,300 @@
+package newebpay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+// 合作商店審核狀態
+type MerchantReviewStatus string
+
+const (
+	MerchantReviewPending   MerchantReviewStatus = "pending"   // 審核中
+	MerchantReviewApproved  MerchantReviewStatus = "approved"  // 審核通過, 商店啟用
+	MerchantReviewRejected  MerchantReviewStatus = "rejected"  // 審核未通過
+	MerchantReviewSuspended MerchantReviewStatus = "suspended" // 商店暫停使用
+)
+
+type RequestQueryMerchant struct {
+	Version    string `json:"Version"`
+	TimeStamp  string `json:"TimeStamp"`
+	MerchantID string `json:"MerchantID"` // 欲查詢的商店代號
+}
+
+// 查詢合作商店的審核狀態及支付方式、手續費、撥款設定, 須先設定 ApiUrlQueryMerchant。
+// 此 API 及回傳欄位 (含 MerchantStatus 代碼) 未列於公開的合作推廣商手冊, 使用前須與藍新確認
+func (a Api) QueryMerchant(partnerId, hashKey, hashIv, merchantId string, requestedAt xtime.Time) (*ResultQueryMerchant, error) {
+	return a.QueryMerchantContext(context.Background(), partnerId, hashKey, hashIv, merchantId, requestedAt)
+}
+
+// 同 QueryMerchant, ctx 取消或逾時時中止請求
+func (a Api) QueryMerchantContext(ctx context.Context, partnerId, hashKey, hashIv, merchantId string, requestedAt xtime.Time) (*ResultQueryMerchant, error) {
+	if merchantId == "" {
+		return nil, errors.New("[query-merchant] missing merchant id")
+	}
+
+	if a.ApiUrlQueryMerchant == "" {
+		return nil, errors.New("[query-merchant] ApiUrlQueryMerchant is not configured")
+	}
+
+	data := RequestQueryMerchant{
+		Version:    "1.0",
+		TimeStamp:  strconv.FormatInt(time.Time(requestedAt).Unix(), 10),
+		MerchantID: merchantId,
+	}
+
+	payload, err := postPartnerFormContext(ctx, a.ApiUrlQueryMerchant, partnerId, hashKey, hashIv, data, "query-merchant")
+	if err != nil {
+		return nil, err
+	}
+
+	var result ResultQueryMerchant
+	if err := json.Unmarshal(payload.Result, &result); err != nil {
+		return nil, fmt.Errorf("[query-merchant] failed to decode result: %w", err)
+	}
+
+	return &result, nil
+}
+
+type ResultQueryMerchant struct {
+	MerchantID     string     `json:"MerchantID"`
+	MerchantName   string     `json:"MerchantName"`
+	MerchantStatus string     `json:"MerchantStatus"` // 商店狀態 0=審核中, 1=啟用, 2=暫停, 3=審核未通過 (代碼未經公開手冊確認)
+	PaymentType    string     `json:"PaymentType"`    // [支付方式代號:啟用狀態] CREDIT:1|WEBATM:0
+	AgreedFee      string     `json:"AgreedFee"`      // 交易手續費 [支付方式代號:費率] CREDIT:0.02
+	AgreedDay      string     `json:"AgreedDay"`      // 撥款天數 [支付方式代號:天數] CREDIT:3
+	CreditLimit    FlexInt    `json:"CreditLimit"`    // 信用卡 30 天收款額
+	ReviewMessage  FlexString `json:"ReviewMessage"`  // 審核說明
+}
+
+func (r ResultQueryMerchant) ReviewStatus() (MerchantReviewStatus, error) {
+	switch r.MerchantStatus {
+	case "0":
+		return MerchantReviewPending, nil
+	case "1":
+		return MerchantReviewApproved, nil
+	case "2":
+		return MerchantReviewSuspended, nil
+	case "3":
+		return MerchantReviewRejected, nil
+	}
+	return "", fmt.Errorf("invalid merchant status: %s", r.MerchantStatus)
+}
+
+// 各支付方式是否啟用
+func (r ResultQueryMerchant) PaymentTypes() (map[string]bool, error) {
+	values, err := parseAgreedValues(r.PaymentType)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make(map[string]bool, len(values))
+	for paymentType, v := range values {
+		enabled[paymentType] = v == "1"
+	}
+	return enabled, nil
+}
+
+// 已啟用的支付方式
+func (r ResultQueryMerchant) EnabledPaymentTypes() ([]string, error) {
+	paymentTypes, err := r.PaymentTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	var enabled []string
+	for paymentType, ok := range paymentTypes {
+		if ok {
+			enabled = append(enabled, paymentType)
+		}
+	}
+	sort.Strings(enabled)
+	return enabled, nil
+}
+
+// 各支付方式的交易手續費率
+func (r ResultQueryMerchant) Fees() (map[string]float64, error) {
+	values, err := parseAgreedValues(r.AgreedFee)
+	if err != nil {
+		return nil, err
+	}
+
+	fees := make(map[string]float64, len(values))
+	for paymentType, v := range values {
+		fee, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AgreedFee: %s", r.AgreedFee)
+		}
+		fees[paymentType] = fee
+	}
+	return fees, nil
+}
+
+// 各支付方式的撥款天數
+func (r ResultQueryMerchant) PayoutDays() (map[string]int, error) {
+	values, err := parseAgreedValues(r.AgreedDay)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make(map[string]int, len(values))
+	for paymentType, v := range values {
+		day, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AgreedDay: %s", r.AgreedDay)
+		}
+		days[paymentType] = day
+	}
+	return days, nil
+}
+
+// 合作商店狀態異動, From 為 nil 時為第一次查詢結果
+type MerchantTransition struct {
+	MerchantID string
+	From       *ResultQueryMerchant
+	To         *ResultQueryMerchant
+	Changes    []string // 異動的欄位
+	At         time.Time
+}
+
+// 定期查詢合作商店狀態, 有異動時呼叫 OnTransition
+type MerchantWatcher struct {
+	Api          *Api
+	PartnerID    string
+	HashKey      string
+	HashIv       string
+	Interval     time.Duration // 預設 10 分鐘
+	Timeout      time.Duration // 單筆查詢逾時, 預設 30 秒
+	PaymentTypes []string      // 選填, 審核通過後須啟用的支付方式, 例如 CREDIT
+	OnTransition func(MerchantTransition)
+	OnError      func(merchantId string, err error) // 選填, 查詢失敗時呼叫, 下次輪詢會再重試
+}
+
+// 商店審核未通過, 或審核通過且 PaymentTypes 皆已啟用時停止查詢
+func (w MerchantWatcher) done(r *ResultQueryMerchant) bool {
+	status, err := r.ReviewStatus()
+	switch {
+	case err != nil:
+		return false
+	case status == MerchantReviewRejected:
+		return true
+	case status != MerchantReviewApproved:
+		return false
+	}
+
+	if len(w.PaymentTypes) == 0 {
+		return true
+	}
+
+	enabled, err := r.PaymentTypes()
+	if err != nil {
+		return false
+	}
+	for _, paymentType := range w.PaymentTypes {
+		if !enabled[strings.ToUpper(paymentType)] {
+			return false
+		}
+	}
+	return true
+}
+
+// 輪詢直到所有商店審核未通過或審核通過且 PaymentTypes 皆已啟用, 或 ctx 結束
+func (w MerchantWatcher) Watch(ctx context.Context, merchantIds []string) error {
+	if w.Api == nil || w.OnTransition == nil {
+		return errors.New("[watch-merchant] missing api or transition handler")
+	}
+
+	interval := w.Interval
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	last := map[string]*ResultQueryMerchant{}
+	pending := append([]string(nil), merchantIds...)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var next []string
+		for _, merchantId := range pending {
+			now := xtime.NowUTC()
+			reqCtx, cancel := context.WithTimeout(ctx, timeout)
+			current, err := w.Api.QueryMerchantContext(reqCtx, w.PartnerID, w.HashKey, w.HashIv, merchantId, now)
+			cancel()
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if w.OnError != nil {
+					w.OnError(merchantId, err)
+				}
+				next = append(next, merchantId)
+				continue
+			}
+
+			if changes := merchantChanges(last[merchantId], current); len(changes) > 0 {
+				w.OnTransition(MerchantTransition{
+					MerchantID: merchantId,
+					From:       last[merchantId],
+					To:         current,
+					Changes:    changes,
+					At:         time.Time(now),
+				})
+			}
+			last[merchantId] = current
+
+			if !w.done(current) {
+				next = append(next, merchantId)
+			}
+		}
+
+		pending = next
+		if len(pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func merchantChanges(from, to *ResultQueryMerchant) []string {
+	if from == nil {
+		return []string{"MerchantStatus", "PaymentType", "AgreedFee", "AgreedDay", "CreditLimit"}
+	}
+
+	var changes []string
+	if from.MerchantStatus != to.MerchantStatus {
+		changes = append(changes, "MerchantStatus")
+	}
+	if from.PaymentType != to.PaymentType {
+		changes = append(changes, "PaymentType")
+	}
+	if from.AgreedFee != to.AgreedFee {
+		changes = append(changes, "AgreedFee")
+	}
+	if from.AgreedDay != to.AgreedDay {
+		changes = append(changes, "AgreedDay")
+	}
+	if from.CreditLimit != to.CreditLimit {
+		changes = append(changes, "CreditLimit")
+	}
+	return changes
+}
@@ -0,0 +1,191 @@
+package newebpay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+func TestResultQueryMerchantReviewStatus(t *testing.T) {
+	tests := []struct {
+		status  string
+		want    MerchantReviewStatus
+		wantErr bool
+	}{
+		{"0", MerchantReviewPending, false},
+		{"1", MerchantReviewApproved, false},
+		{"2", MerchantReviewSuspended, false},
+		{"3", MerchantReviewRejected, false},
+		{"9", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ResultQueryMerchant{MerchantStatus: tt.status}.ReviewStatus()
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ReviewStatus(%q) = %s, %v", tt.status, got, err)
+		}
+	}
+}
+
+func TestResultQueryMerchantPaymentTypes(t *testing.T) {
+	r := ResultQueryMerchant{PaymentType: "CREDIT:1|webatm:0|VACC:1"}
+	paymentTypes, err := r.PaymentTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !paymentTypes["CREDIT"] || paymentTypes["WEBATM"] || !paymentTypes["VACC"] || len(paymentTypes) != 3 {
+		t.Errorf("PaymentTypes() = %v", paymentTypes)
+	}
+
+	enabled, err := r.EnabledPaymentTypes()
+	if err != nil || len(enabled) != 2 || enabled[0] != "CREDIT" || enabled[1] != "VACC" {
+		t.Errorf("EnabledPaymentTypes() = %v, %v", enabled, err)
+	}
+
+	for _, invalid := range []string{"", "CREDIT", "CREDIT:1|:0"} {
+		if _, err := (ResultQueryMerchant{PaymentType: invalid}).PaymentTypes(); err == nil {
+			t.Errorf("PaymentTypes(%q): expected error", invalid)
+		}
+	}
+}
+
+func TestQueryMerchant(t *testing.T) {
+	partner := newFakePartner(t, map[string]any{"MerchantID": "MS1", "MerchantStatus": "1", "CreditLimit": "100000"})
+	a := Api{ApiUrlQueryMerchant: partner.URL}
+
+	result, err := a.QueryMerchant("PT1", testMerchant.HashKey, testMerchant.HashIv, "MS1", xtime.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MerchantID != "MS1" || result.CreditLimit != 100000 {
+		t.Errorf("QueryMerchant() = %+v", result)
+	}
+	if got := partner.postData.Get("MerchantID"); got != "MS1" {
+		t.Errorf("MerchantID = %q", got)
+	}
+
+	if _, err := (Api{}).QueryMerchant("PT1", testMerchant.HashKey, testMerchant.HashIv, "MS1", xtime.NowUTC()); err == nil {
+		t.Error("expected error without ApiUrlQueryMerchant")
+	}
+}
+
+// 依請求次數回傳 results, 超過時重複最後一筆
+func newSequencePartner(t *testing.T, results ...ResultQueryMerchant) *httptest.Server {
+	t.Helper()
+
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		result := results[min(requests, len(results)-1)]
+		requests++
+		mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]any{"status": "SUCCESS", "message": "", "result": result})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMerchantWatcherWaitsForPaymentTypes(t *testing.T) {
+	srv := newSequencePartner(t,
+		ResultQueryMerchant{MerchantID: "MS1", MerchantStatus: "0", PaymentType: "CREDIT:0"},
+		ResultQueryMerchant{MerchantID: "MS1", MerchantStatus: "1", PaymentType: "CREDIT:0"},
+		ResultQueryMerchant{MerchantID: "MS1", MerchantStatus: "1", PaymentType: "CREDIT:0"},
+		ResultQueryMerchant{MerchantID: "MS1", MerchantStatus: "1", PaymentType: "CREDIT:1"},
+	)
+
+	var transitions []MerchantTransition
+	w := MerchantWatcher{
+		Api:          &Api{ApiUrlQueryMerchant: srv.URL},
+		HashKey:      testMerchant.HashKey,
+		HashIv:       testMerchant.HashIv,
+		Interval:     5 * time.Millisecond,
+		PaymentTypes: []string{"credit"},
+		OnTransition: func(tr MerchantTransition) { transitions = append(transitions, tr) },
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := w.Watch(ctx, []string{"MS1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transitions) != 3 {
+		t.Fatalf("transitions = %d, want 3", len(transitions))
+	}
+	if transitions[0].From != nil {
+		t.Errorf("first transition From = %+v, want nil", transitions[0].From)
+	}
+	if changes := transitions[1].Changes; len(changes) != 1 || changes[0] != "MerchantStatus" {
+		t.Errorf("approved transition Changes = %v", changes)
+	}
+	if changes := transitions[2].Changes; len(changes) != 1 || changes[0] != "PaymentType" || transitions[2].To.PaymentType != "CREDIT:1" {
+		t.Errorf("payment type transition = %+v", transitions[2])
+	}
+}
+
+func TestMerchantWatcherStopsOnRejected(t *testing.T) {
+	srv := newSequencePartner(t, ResultQueryMerchant{MerchantID: "MS1", MerchantStatus: "3", PaymentType: "CREDIT:0"})
+
+	w := MerchantWatcher{
+		Api:          &Api{ApiUrlQueryMerchant: srv.URL},
+		HashKey:      testMerchant.HashKey,
+		HashIv:       testMerchant.HashIv,
+		Interval:     5 * time.Millisecond,
+		PaymentTypes: []string{"CREDIT"},
+		OnTransition: func(MerchantTransition) {},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := w.Watch(ctx, []string{"MS1"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMerchantWatcherRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var errs []error
+	w := MerchantWatcher{
+		Api:          &Api{ApiUrlQueryMerchant: srv.URL},
+		HashKey:      testMerchant.HashKey,
+		HashIv:       testMerchant.HashIv,
+		Interval:     5 * time.Millisecond,
+		Timeout:      20 * time.Millisecond,
+		OnTransition: func(MerchantTransition) {},
+		OnError: func(merchantId string, err error) {
+			errs = append(errs, err)
+			cancel()
+		},
+	}
+
+	started := time.Now()
+	if err := w.Watch(ctx, []string{"MS1"}); err != context.Canceled {
+		t.Errorf("Watch() = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("Watch took %v", elapsed)
+	}
+	if len(errs) != 1 {
+		t.Errorf("OnError called %d times, want 1", len(errs))
+	}
+}
@@ -0,0 +1,99 @@
+package newebpay
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Loopmaas/xtime"
+	"github.com/Loopmaas/xuuid"
+)
+
+func TestResultAddMerchantMemberType(t *testing.T) {
+	for _, data := range []string{`{"MemberType":5}`, `{"MemberType":"5"}`} {
+		var result ResultAddMerchant
+		if err := json.Unmarshal([]byte(data), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.MemberType != MemberTypeCorporate {
+			t.Errorf("MemberType of %s = %q", data, result.MemberType)
+		}
+	}
+}
+
+func TestRedactPartnerResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"credentials", `{"status":"SUCCESS","message":"","result":{"MerchantID":"LOP1","MerchantHashKey":"k","MerchantIvKey":"iv"}}`,
+			`{"message":"","result":{"MerchantHashKey":"***","MerchantID":"LOP1","MerchantIvKey":"***"},"status":"SUCCESS"}`},
+		{"capitalized keys", `{"Status":"SUCCESS","Message":"","Result":{"MerchantID":"LOP1","merchantHashKey":"k","MerchantIvKey":"iv"}}`,
+			`{"Message":"","Result":{"MerchantID":"LOP1","MerchantIvKey":"***","merchantHashKey":"***"},"Status":"SUCCESS"}`},
+		{"no credentials", `{"status":"SUCCESS","result":{"MerchantID":"LOP1"}}`, `{"status":"SUCCESS","result":{"MerchantID":"LOP1"}}`},
+		{"string result", `{"status":"ERROR","result":"error"}`, `{"status":"ERROR","result":"error"}`},
+		{"not json", `<html>`, `<html>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactPartnerResponse([]byte(tt.data)); got != tt.want {
+				t.Errorf("redactPartnerResponse() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostPartnerFormDecodeErrorOmitsBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"status":"SUCCESS","result":{"MerchantHashKey":"secret-hash-key"`)
+	}))
+	defer srv.Close()
+
+	_, err := postPartnerForm(srv.URL, "P1", testMerchant.HashKey, testMerchant.HashIv, RequestQueryMerchant{MerchantID: "MS1"}, "query-merchant")
+	if err == nil {
+		t.Fatal("expected decode error")
+	}
+	if strings.Contains(err.Error(), "secret-hash-key") {
+		t.Errorf("error leaks response body: %v", err)
+	}
+}
+
+func TestAddMerchantDoesNotLogCredentials(t *testing.T) {
+	const hashKey, hashIv = "merchant-hash-key-0123456789abcdef", "merchant-iv-0123"
+	partner := newFakePartner(t, map[string]any{"MerchantID": "LOP123", "MerchantHashKey": hashKey, "MerchantIvKey": hashIv, "MemberType": 5})
+	a := Api{ApiUrlAddMerchant: partner.URL}
+
+	root, _ := url.Parse("https://app.example.com")
+	data, err := NewRequestAddMerchant(testMember(), testMerchantDetail(), root, xuuid.New(), "LOP123", xtime.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	result, err := a.AddMerchant("P1", testMerchant.HashKey, testMerchant.HashIv, data)
+	os.Stdout = stdout
+	w.Close()
+	logged, _ := io.ReadAll(r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MerchantHashKey != hashKey || result.MemberType != MemberTypeCorporate {
+		t.Errorf("AddMerchant() = %+v", result)
+	}
+	if strings.Contains(string(logged), hashKey) || strings.Contains(string(logged), hashIv) {
+		t.Errorf("merchant credentials logged: %s", logged)
+	}
+}
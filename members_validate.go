@@ -0,0 +1,206 @@
+package newebpay
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 單一欄位驗證失敗, Field 為 JSON 欄位名稱
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Member、MerchantDetail 驗證失敗的所有欄位, 可用 errors.As 取出
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Error()
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// 依欄位名稱查詢錯誤訊息
+func (e ValidationErrors) Field(field string) (string, bool) {
+	for _, fe := range e {
+		if fe.Field == field {
+			return fe.Message, true
+		}
+	}
+	return "", false
+}
+
+var (
+	nationalIdPattern   = regexp.MustCompile(`^[A-Z][0-9]{9}$`)
+	loginAccountPattern = regexp.MustCompile(`^[0-9a-zA-Z_.@]{5,20}$`)
+	mobilePattern       = regexp.MustCompile(`^09[0-9]{8}$`)
+	phonePattern        = regexp.MustCompile(`^0[0-9]{1,3}-?[0-9]{6,8}(#[0-9]{1,6})?$`)
+	englishNamePattern  = regexp.MustCompile(`^[A-Za-z][A-Za-z .'\-]*,[A-Za-z][A-Za-z .'\-]*$`)
+	asciiPattern        = regexp.MustCompile(`^[\x20-\x7E]+$`)
+	digitsPattern       = regexp.MustCompile(`^[0-9]+$`)
+	zipcodePattern      = regexp.MustCompile(`^[0-9]{3}$`)
+	bankCodePattern     = regexp.MustCompile(`^[0-9]{3}$`)
+	subBankCodePattern  = regexp.MustCompile(`^[0-9]{4}$`)
+	bankAccountPattern  = regexp.MustCompile(`^[0-9]{6,16}$`)
+)
+
+type fieldValidator struct {
+	errs ValidationErrors
+}
+
+func (v *fieldValidator) add(field, format string, args ...any) {
+	v.errs = append(v.errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// 必填及長度 (以字元數計), 通過時回傳 true 以便繼續檢查格式
+func (v *fieldValidator) required(field, value string, maxLen int) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "required")
+		return false
+	}
+	if n := utf8.RuneCountInString(value); maxLen > 0 && n > maxLen {
+		v.add(field, "must be at most %d characters, got %d", maxLen, n)
+		return false
+	}
+	return true
+}
+
+func (v *fieldValidator) match(field, value string, pattern *regexp.Regexp, format string) {
+	if !pattern.MatchString(value) {
+		v.add(field, "invalid format, expected %s", format)
+	}
+}
+
+func (v *fieldValidator) date(field, value string) {
+	if _, err := time.Parse("20060102", value); err != nil {
+		v.add(field, "invalid date, expected YYYYMMDD")
+	}
+}
+
+func (v *fieldValidator) emails(field, value string, multiple bool) {
+	addresses := []string{value}
+	if multiple {
+		addresses = strings.Split(value, ",")
+	}
+
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		parsed, err := mail.ParseAddress(address)
+		if err != nil || parsed.Address != address {
+			v.add(field, "invalid email: %s", address)
+			return
+		}
+	}
+}
+
+func (v *fieldValidator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// 依藍新金流欄位規則驗證會員資料, 失敗時回傳 ValidationErrors。
+// 企業會員的 MemberUnified 須為統一編號, 並須填寫代表人、資本額、核准設立日期及公司登記地址;
+// 個人會員的 MemberUnified 須為身分證字號, 不檢查上述企業欄位。
+func (m Member) Validate(memberType MemberType) error {
+	var v fieldValidator
+
+	switch memberType {
+	case MemberTypeCorporate:
+		if v.required("MemberUnified", m.MemberUnified, 8) {
+			if err := ValidateUBN(m.MemberUnified); err != nil {
+				v.add("MemberUnified", "invalid UBN")
+			}
+		}
+		v.required("RepresentName", m.RepresentName, 60)
+		if v.required("CapitalAmount", m.CapitalAmount, 16) {
+			v.match("CapitalAmount", m.CapitalAmount, digitsPattern, "digits")
+		}
+		if v.required("IncorporationDate", m.IncorporationDate, 8) {
+			v.date("IncorporationDate", m.IncorporationDate)
+		}
+		v.required("CompanyAddress", m.CompanyAddress, 100)
+	case MemberTypeIndividual:
+		if v.required("MemberUnified", m.MemberUnified, 10) {
+			v.match("MemberUnified", m.MemberUnified, nationalIdPattern, "national ID")
+		}
+	default:
+		v.add("MemberType", "invalid member type: %s", memberType)
+	}
+
+	v.required("MemberName", m.MemberName, 60)
+	if v.required("MemberPhone", m.MemberPhone, 20) {
+		v.match("MemberPhone", m.MemberPhone, phonePattern, "area code and number, e.g. 02-22442424")
+	}
+	v.required("MemberAddress", m.MemberAddress, 100)
+	v.required("ManagerName", m.ManagerName, 20)
+	if v.required("ManagerNameE", m.ManagerNameE, 100) {
+		v.match("ManagerNameE", m.ManagerNameE, englishNamePattern, "{first name},{last name} in English letters")
+	}
+	if v.required("LoginAccount", m.LoginAccount, 20) {
+		v.match("LoginAccount", m.LoginAccount, loginAccountPattern, `[0-9a-zA-Z_.@]{5,20}`)
+	}
+	if v.required("ManagerMobile", m.ManagerMobile, 10) {
+		v.match("ManagerMobile", m.ManagerMobile, mobilePattern, "09XXXXXXXX")
+	}
+	if v.required("ManagerEmail", m.ManagerEmail, 100) {
+		v.emails("ManagerEmail", m.ManagerEmail, false)
+	}
+	if v.required("DisputeMail", m.DisputeMail, 100) {
+		v.emails("DisputeMail", m.DisputeMail, false)
+	}
+
+	return v.err()
+}
+
+// 依藍新金流欄位規則驗證商店資料, 失敗時回傳 ValidationErrors
+func (d MerchantDetail) Validate() error {
+	var v fieldValidator
+
+	if v.required("MerchantEmail", d.MerchantEmail, 255) {
+		v.emails("MerchantEmail", d.MerchantEmail, true)
+	}
+	v.required("MerchantName", d.MerchantName, 20)
+	if v.required("MerchantNameE", d.MerchantNameE, 100) {
+		v.match("MerchantNameE", d.MerchantNameE, asciiPattern, "English letters")
+	}
+	v.required("MerchantAddrCity", d.MerchantAddrCity, 10)
+	v.required("MerchantAddrArea", d.MerchantAddrArea, 10)
+	if v.required("MerchantAddrCode", d.MerchantAddrCode, 3) {
+		v.match("MerchantAddrCode", d.MerchantAddrCode, zipcodePattern, "3-digit zipcode")
+	}
+	v.required("MerchantAddr", d.MerchantAddr, 60)
+	if v.required("MerchantEnAddr", d.MerchantEnAddr, 255) {
+		v.match("MerchantEnAddr", d.MerchantEnAddr, asciiPattern, "English letters")
+	}
+	if v.required("NationalE", d.NationalE, 60) {
+		v.match("NationalE", d.NationalE, asciiPattern, "English letters")
+	}
+	if v.required("CityE", d.CityE, 60) {
+		v.match("CityE", d.CityE, asciiPattern, "English letters")
+	}
+	v.required("MerchantDesc", d.MerchantDesc, 255)
+	if v.required("BankCode", d.BankCode, 3) {
+		v.match("BankCode", d.BankCode, bankCodePattern, "3-digit bank code")
+	}
+	if v.required("SubBankCode", d.SubBankCode, 4) {
+		v.match("SubBankCode", d.SubBankCode, subBankCodePattern, "4-digit branch code")
+	}
+	if v.required("BankAccount", d.BankAccount, 16) {
+		v.match("BankAccount", d.BankAccount, bankAccountPattern, "6 to 16 digits")
+	}
+	v.required("AccountName", d.AccountName, 60)
+
+	return v.err()
+}
@@ -0,0 +1,92 @@
+package newebpay
+
+import (
+	"errors"
+	"testing"
+)
+
+func testIndividualMember() *Member {
+	m := testMember()
+	m.MemberUnified = "A123456789"
+	m.RepresentName, m.CapitalAmount, m.IncorporationDate, m.CompanyAddress = "", "", "", ""
+	return m
+}
+
+func TestMemberValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		member     *Member
+		memberType MemberType
+		wantFields []string
+	}{
+		{"corporate", testMember(), MemberTypeCorporate, nil},
+		{"individual without corporate fields", testIndividualMember(), MemberTypeIndividual, nil},
+		{"individual with UBN", testMember(), MemberTypeIndividual, []string{"MemberUnified"}},
+		{"corporate with national ID", testIndividualMember(), MemberTypeCorporate,
+			[]string{"MemberUnified", "RepresentName", "CapitalAmount", "IncorporationDate", "CompanyAddress"}},
+		{"invalid member type", testMember(), MemberType("2"), []string{"MemberType"}},
+		{"invalid contact", func() *Member {
+			m := testMember()
+			m.ManagerNameE = "Wang Xiao Ming"
+			m.LoginAccount = "ab"
+			m.ManagerEmail = "admin"
+			m.MemberPhone = "22442424"
+			return m
+		}(), MemberTypeCorporate, []string{"ManagerNameE", "LoginAccount", "ManagerEmail", "MemberPhone"}},
+		{"invalid corporate formats", func() *Member {
+			m := testMember()
+			m.MemberUnified = "04595258"
+			m.CapitalAmount = "1,000,000"
+			m.IncorporationDate = "2020-01-01"
+			return m
+		}(), MemberTypeCorporate, []string{"MemberUnified", "CapitalAmount", "IncorporationDate"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.member.Validate(tt.memberType)
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Errorf("Validate() = %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Validate() = %v, want ValidationErrors", err)
+			}
+			if len(errs) != len(tt.wantFields) {
+				t.Errorf("Validate() = %v, want fields %v", errs, tt.wantFields)
+			}
+			for _, field := range tt.wantFields {
+				if _, ok := errs.Field(field); !ok {
+					t.Errorf("missing error of %s in %v", field, errs)
+				}
+			}
+		})
+	}
+}
+
+func TestMerchantDetailValidate(t *testing.T) {
+	if err := testMerchantDetail().Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	d := testMerchantDetail()
+	d.MerchantEmail = "cs@example.com, bad"
+	d.MerchantAddrCode = "10491"
+	d.BankAccount = "12345"
+	d.MerchantNameE = "某某租車"
+	d.AccountName = ""
+
+	var errs ValidationErrors
+	if !errors.As(d.Validate(), &errs) {
+		t.Fatal("expected ValidationErrors")
+	}
+	for _, field := range []string{"MerchantEmail", "MerchantAddrCode", "BankAccount", "MerchantNameE", "AccountName"} {
+		if _, ok := errs.Field(field); !ok {
+			t.Errorf("missing error of %s in %v", field, errs)
+		}
+	}
+}
@@ -86,6 +86,55 @@ func (a Api) GetBindingCreditCardParams(
 	}, nil
 }
 
+// 一般 MPG 信用卡付款, terms 為開放付款人選擇的分期期數, 未帶入時不開啟分期
+func (a Api) GetMPGTransactionParams(
+	merchant *Merchant, policy InstallmentPolicy,
+	merchantOrderNo string, amount int, itemDesc string,
+	email string, terms []InstallmentTerm,
+	returnUrl, notifyUrl, clientBackUrl string,
+	requestedAt xtime.Time,
+) (*MPGTransaction, error) {
+	if err := policy.Validate(amount, terms...); err != nil {
+		return nil, err
+	}
+
+	tradeInfo := MPGTradeInfo{
+		MerchantID:        merchant.MerchantId,
+		RespondType:       "JSON",
+		TimeStamp:         strconv.FormatInt(time.Time(requestedAt).Unix(), 10),
+		Version:           "2.1",
+		LangType:          "zh-tw",
+		MerchantOrderNo:   merchantOrderNo,
+		Amt:               amount,
+		ItemDesc:          itemDesc,
+		ReturnURL:         returnUrl,
+		NotifyURL:         notifyUrl,
+		ClientBackURL:     clientBackUrl,
+		Email:             email,
+		EmailModify:       0,
+		CREDITAEAGREEMENT: 0,
+		InstFlag:          installmentFlag(terms),
+		OrderComment:      "",
+		CREDITAGREEMENT:   0,
+		TokenTerm:         "",
+		TokenLife:         nil,
+		UseFor:            0,
+	}
+
+	encTradeInfo, err := encryptData(tradeInfo, merchant.HashKey, merchant.HashIv)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MPGTransaction{
+		MerchantID:  merchant.MerchantId,
+		TradeInfo:   encTradeInfo,
+		TradeSha:    encryptDataSha256(encTradeInfo, merchant.HashKey, merchant.HashIv),
+		Version:     "2.1",
+		EncryptType: "0",
+	}, nil
+}
+
 type RespMPGTradeInfo struct {
 	Status  string              `json:"Status"`
 	Message string              `json:"Message"`
@@ -121,6 +170,14 @@ func (r RespMPGTradeInfo) IsSuccess() bool {
 	return r.Status == "SUCCESS"
 }
 
+func (r ResultMPGTradeInfo) Installment() *InstallmentSchedule {
+	return newInstallmentSchedule(r.Inst, r.InstFirst, r.InstEach)
+}
+
+func (r ResultMPGTradeInfo) paidOrder() (string, string, int) {
+	return r.MerchantOrderNo, r.TradeNo, r.Amt
+}
+
 func (r RespMPGTradeInfo) GetCreditCardInfo() (string, string, string, string, error) {
 	expires, err := convertExpiresToLastDay(r.Result.Exp)
 	return r.Result.TokenValue, expires, r.Result.Card6No, r.Result.Card4No, err
@@ -1,13 +1,14 @@
 package newebpay
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -15,38 +16,79 @@ import (
 	"github.com/Loopmaas/xtime"
 )
 
+// 單筆交易查詢參數, 依藍新金流線上交易-幕前支付技術串接手冊「單筆交易查詢」(/API/QueryTradeInfo)。
+// 手冊僅提供以商店訂單編號查詢; Gateway 僅複合式商店 (合作推廣商/平台型) 帶入 Composite。
 type QueryPostData struct {
 	MerchantID      string `json:"MerchantID"`
 	Version         string `json:"Version"`     // 1.3
 	RespondType     string `json:"RespondType"` // JSON
-	CheckValue      string `json:"CheckValue"`  // IV
+	CheckValue      string `json:"CheckValue"`  // SHA256(IV={HashIV}&Amt={Amt}&MerchantID={MerchantID}&MerchantOrderNo={MerchantOrderNo}&Key={HashKey})
 	TimeStamp       string `json:"TimeStamp"`
 	MerchantOrderNo string `json:"MerchantOrderNo"`
 	Amt             int    `json:"Amt"`
-	Gateway         string `json:"Gateway"` // Composite
+	Gateway         string `json:"Gateway,omitempty"` // Composite
+}
+
+const (
+	QueryGatewayComposite = "Composite" // 合作推廣商/平台型商店查詢
+)
+
+type QueryTradeInfoRequest struct {
+	MerchantOrderNo string // 商店訂單編號
+	Amt             int    // 訂單金額
+	Gateway         string // 空值=一般商店, Composite=合作推廣商/平台型商店
 }
 
 func (a Api) QueryTradeInfo(m *Merchant, merchantOrderNo string, amount int, requestedAt xtime.Time) (*RespQueryTradeInfo, error) {
+	return a.QueryTradeInfoBy(m, QueryTradeInfoRequest{
+		MerchantOrderNo: merchantOrderNo,
+		Amt:             amount,
+	}, requestedAt)
+}
+
+func (a Api) QueryTradeInfoBy(m *Merchant, req QueryTradeInfoRequest, requestedAt xtime.Time) (*RespQueryTradeInfo, error) {
+	return a.QueryTradeInfoContext(context.Background(), m, req, requestedAt)
+}
+
+// 查詢交易的 HTTP client, 避免單一請求無回應時卡住呼叫端
+var queryHttpClient = &http.Client{Timeout: 30 * time.Second}
+
+// 同 QueryTradeInfoBy, ctx 取消或逾時時中止請求
+func (a Api) QueryTradeInfoContext(ctx context.Context, m *Merchant, req QueryTradeInfoRequest, requestedAt xtime.Time) (*RespQueryTradeInfo, error) {
+	if req.MerchantOrderNo == "" {
+		return nil, errors.New("[query] missing MerchantOrderNo")
+	}
+
 	// generate check value
-	checkValueData := fmt.Sprintf("IV=%s&Amt=%d&MerchantID=%s&MerchantOrderNo=%s&Key=%s", m.HashIv, amount, m.MerchantId, merchantOrderNo, m.HashKey)
+	checkValueData := fmt.Sprintf("IV=%s&Amt=%d&MerchantID=%s&MerchantOrderNo=%s&Key=%s", m.HashIv, req.Amt, m.MerchantId, req.MerchantOrderNo, m.HashKey)
 	hash := sha256.Sum256([]byte(checkValueData))
-	checkValue := strings.ToUpper(hex.EncodeToString(hash[:]))
-	unixTimestamp := time.Time(requestedAt).UTC().Unix()
 
-	formData := url.Values{
-		"MerchantID":      {m.MerchantId},
-		"Version":         {"1.3"},
-		"RespondType":     {"JSON"},
-		"CheckValue":      {checkValue},
-		"TimeStamp":       {strconv.FormatInt(unixTimestamp, 10)},
-		"MerchantOrderNo": {merchantOrderNo},
-		"Amt":             {strconv.FormatInt(int64(amount), 10)},
+	postData := QueryPostData{
+		MerchantID:      m.MerchantId,
+		Version:         "1.3",
+		RespondType:     "JSON",
+		CheckValue:      strings.ToUpper(hex.EncodeToString(hash[:])),
+		TimeStamp:       strconv.FormatInt(time.Time(requestedAt).UTC().Unix(), 10),
+		MerchantOrderNo: req.MerchantOrderNo,
+		Amt:             req.Amt,
+		Gateway:         req.Gateway,
+	}
+	formData, err := httpBuildQuery(postData)
+	if err != nil {
+		return nil, fmt.Errorf("[query] %w", err)
 	}
 
-	fmt.Printf("查詢信用卡交易, transactionId:%s, get url: %s, check value: %s, formData: %s", merchantOrderNo, a.ApiUrlQueryTradeInfo, checkValueData, formData)
+	transactionId := req.MerchantOrderNo
+	fmt.Printf("查詢信用卡交易, transactionId:%s, get url: %s, check value: %s, formData: %s", transactionId, a.ApiUrlQueryTradeInfo, checkValueData, formData)
 
-	resp, err := http.PostForm(a.ApiUrlQueryTradeInfo, formData)
-	fmt.Printf("查詢信用卡交易 api 結果, transactionId:%s, get url: %s, check value: %s, formData: %s, resp: %v, err: %s", merchantOrderNo, a.ApiUrlQueryTradeInfo, checkValueData, formData, resp, err)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.ApiUrlQueryTradeInfo, strings.NewReader(formData))
+	if err != nil {
+		return nil, fmt.Errorf("[query] failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := queryHttpClient.Do(httpReq)
+	fmt.Printf("查詢信用卡交易 api 結果, transactionId:%s, get url: %s, check value: %s, formData: %s, resp: %v, err: %s", transactionId, a.ApiUrlQueryTradeInfo, checkValueData, formData, resp, err)
 
 	if err != nil {
 		return nil, fmt.Errorf("Failed to submit form: %v", err)
@@ -54,7 +96,7 @@ func (a Api) QueryTradeInfo(m *Merchant, merchantOrderNo string, amount int, req
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("查詢信用卡交易失敗, transactionId:%s, get url: %s, check value: %s, formData: %s, resp: %v", merchantOrderNo, a.ApiUrlQueryTradeInfo, checkValueData, formData, resp)
+		fmt.Printf("查詢信用卡交易失敗, transactionId:%s, get url: %s, check value: %s, formData: %s, resp: %v", transactionId, a.ApiUrlQueryTradeInfo, checkValueData, formData, resp)
 		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
@@ -68,9 +110,9 @@ func (a Api) QueryTradeInfo(m *Merchant, merchantOrderNo string, amount int, req
 		return nil, fmt.Errorf("[query] failed to decode response: %v, received data: %s", err, string(receivedData))
 	}
 
-	fmt.Printf("查詢信用卡交易請求結果, transactionId:%s, get url: %s, check value: %s, formData: %s, response: %v", merchantOrderNo, a.ApiUrlQueryTradeInfo, checkValueData, formData, tp)
+	fmt.Printf("查詢信用卡交易請求結果, transactionId:%s, get url: %s, check value: %s, formData: %s, response: %v", transactionId, a.ApiUrlQueryTradeInfo, checkValueData, formData, tp)
 	if !tp.IsSuccess() {
-		return nil, fmt.Errorf("[query] %s: %s", tp.Status, tp.Message)
+		return nil, &QueryError{Status: tp.Status, Message: tp.Message}
 	}
 
 	payload := RespQueryTradeInfo{
@@ -81,9 +123,39 @@ func (a Api) QueryTradeInfo(m *Merchant, merchantOrderNo string, amount int, req
 		return nil, fmt.Errorf("[query] assert: %v", err)
 	}
 
+	if err := a.verifyCheckCode(payload.Result.VerifyCheckCode(m.HashKey, m.HashIv)); err != nil {
+		return nil, fmt.Errorf("[query] %w", err)
+	}
+
 	return &payload, nil
 }
 
+// 藍新金流回傳 Status 非 SUCCESS, 例如查無交易資料
+type QueryError struct {
+	Status  string
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("[query] %s: %s", e.Status, e.Message)
+}
+
+// 單筆交易查詢 (QueryTradeInfo) 的回應代碼
+const (
+	QueryStatusNotFound       = "TRA10021" // 查無此筆交易
+	QueryStatusAmountMismatch = "TRA10016" // 查詢金額與交易金額不符
+)
+
+// 藍新金流查無此交易
+func (e *QueryError) IsNotFound() bool {
+	return e.Status == QueryStatusNotFound
+}
+
+// 查詢帶入的 Amt 與藍新金流的交易金額不符
+func (e *QueryError) IsAmountMismatch() bool {
+	return e.Status == QueryStatusAmountMismatch
+}
+
 type RespQueryTradeInfo struct {
 	Status  string               `json:"Status"`
 	Message string               `json:"Message"`
@@ -161,3 +233,21 @@ type ResultQueryTradeInfo struct {
 	Card4No         string `json:"Card4No"`
 	AuthBank        string `json:"AuthBank"`
 }
+
+func (r ResultQueryTradeInfo) Installment() (*InstallmentSchedule, error) {
+	return parseInstallmentSchedule(r.Inst, r.InstFirst, r.InstEach)
+}
+
+// 未回傳 CheckCode 時回傳 ErrCheckCodeMissing, 是否視為通過由 Api.StrictCheckCode 決定
+func (r ResultQueryTradeInfo) VerifyCheckCode(hashKey, hashIv string) (bool, error) {
+	if r.CheckCode == "" {
+		return false, ErrCheckCodeMissing
+	}
+
+	checkCode, err := genCheckCode(r.Amt, r.MerchantID, r.MerchantOrderNo, r.TradeNo, hashKey, hashIv)
+	if err != nil {
+		return false, err
+	}
+
+	return (checkCode == r.CheckCode), nil
+}
@@ -0,0 +1,82 @@
+package newebpay
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+type BulkQueryOptions struct {
+	Workers  int           // 同時查詢的數量, 預設 1
+	Interval time.Duration // 兩次查詢之間的最小間隔 (所有 worker 共用), 0=不限制
+	Timeout  time.Duration // 單筆查詢逾時, 預設 30 秒
+}
+
+type BulkQueryResult struct {
+	Request QueryTradeInfoRequest
+	Resp    *RespQueryTradeInfo
+	Err     error
+}
+
+// 批次查詢交易, 回傳結果與 reqs 順序相同; ctx 取消後尚未查詢的訂單會回傳 ctx.Err()
+func (a Api) BulkQueryTradeInfo(ctx context.Context, m *Merchant, reqs []QueryTradeInfoRequest, opts BulkQueryOptions) []BulkQueryResult {
+	results := make([]BulkQueryResult, len(reqs))
+	for i, req := range reqs {
+		results[i].Request = req
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(reqs) {
+		workers = len(reqs)
+	}
+
+	var throttle <-chan time.Time
+	if opts.Interval > 0 {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+
+				reqCtx, cancel := context.WithTimeout(ctx, timeout)
+				results[i].Resp, results[i].Err = a.QueryTradeInfoContext(reqCtx, m, results[i].Request, xtime.NowUTC())
+				cancel()
+			}
+		}()
+	}
+
+	for i := range reqs {
+		if throttle != nil && i > 0 {
+			select {
+			case <-throttle:
+			case <-ctx.Done():
+			}
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
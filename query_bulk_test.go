@@ -0,0 +1,55 @@
+package newebpay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBulkQueryTradeInfoTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	a := Api{ApiUrlQueryTradeInfo: srv.URL}
+	m := NewMerchant("MS123", "12345678901234567890123456789012", "1234567890123456")
+	reqs := []QueryTradeInfoRequest{
+		{MerchantOrderNo: "A1", Amt: 100},
+		{MerchantOrderNo: "A2", Amt: 200},
+	}
+
+	started := time.Now()
+	results := a.BulkQueryTradeInfo(context.Background(), m, reqs, BulkQueryOptions{Workers: 2, Timeout: 50 * time.Millisecond})
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("BulkQueryTradeInfo took %v", elapsed)
+	}
+
+	for i, result := range results {
+		if result.Request != reqs[i] {
+			t.Errorf("results[%d].Request = %+v, want %+v", i, result.Request, reqs[i])
+		}
+		if result.Err == nil {
+			t.Errorf("results[%d].Err = nil, want timeout", i)
+		}
+	}
+}
+
+func TestBulkQueryTradeInfoCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	a := Api{ApiUrlQueryTradeInfo: "http://127.0.0.1:0"}
+	m := NewMerchant("MS123", "12345678901234567890123456789012", "1234567890123456")
+	results := a.BulkQueryTradeInfo(ctx, m, []QueryTradeInfoRequest{{MerchantOrderNo: "A1"}}, BulkQueryOptions{})
+	if results[0].Err != context.Canceled {
+		t.Errorf("Err = %v, want context.Canceled", results[0].Err)
+	}
+}
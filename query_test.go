@@ -0,0 +1,136 @@
+package newebpay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Loopmaas/xtime"
+)
+
+// 模擬藍新金流 API, 記錄收到的表單並回傳指定的 Result 物件
+type fakeNewebPay struct {
+	*httptest.Server
+	status   string
+	message  string
+	result   any
+	postForm url.Values
+}
+
+func newFakeNewebPay(t *testing.T, result any) *fakeNewebPay {
+	t.Helper()
+
+	f := &fakeNewebPay{status: "SUCCESS", result: result}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm: %v", err)
+		}
+		f.postForm = r.PostForm
+
+		json.NewEncoder(w).Encode(RespPayload{Status: f.status, Message: f.message, Result: f.result})
+	}))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+func testQueryResult(t *testing.T, merchantOrderNo string, amount int) ResultQueryTradeInfo {
+	t.Helper()
+
+	result := ResultQueryTradeInfo{
+		MerchantID:      testMerchant.MerchantId,
+		Amt:             amount,
+		TradeNo:         "23092714215835071",
+		MerchantOrderNo: merchantOrderNo,
+		TradeStatus:     "1",
+		CloseStatus:     "3",
+	}
+	checkCode, err := genCheckCode(result.Amt, result.MerchantID, result.MerchantOrderNo, result.TradeNo, testMerchant.HashKey, testMerchant.HashIv)
+	if err != nil {
+		t.Fatalf("genCheckCode: %v", err)
+	}
+	result.CheckCode = checkCode
+
+	return result
+}
+
+func TestQueryTradeInfoPostForm(t *testing.T) {
+	srv := newFakeNewebPay(t, testQueryResult(t, "ORDER001", 1200))
+	a := Api{ApiUrlQueryTradeInfo: srv.URL}
+	requestedAt := xtime.Time(time.Unix(1695795718, 0))
+
+	tests := []struct {
+		name    string
+		gateway string
+	}{
+		{"一般商店", ""},
+		{"複合式商店", QueryGatewayComposite},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := a.QueryTradeInfoContext(context.Background(), testMerchant, QueryTradeInfoRequest{
+				MerchantOrderNo: "ORDER001",
+				Amt:             1200,
+				Gateway:         tt.gateway,
+			}, requestedAt)
+			if err != nil {
+				t.Fatalf("QueryTradeInfoContext: %v", err)
+			}
+			if resp.Result.TradeNo != "23092714215835071" {
+				t.Errorf("Result.TradeNo = %q", resp.Result.TradeNo)
+			}
+
+			hash := sha256.Sum256([]byte(fmt.Sprintf("IV=%s&Amt=1200&MerchantID=%s&MerchantOrderNo=ORDER001&Key=%s", testMerchant.HashIv, testMerchant.MerchantId, testMerchant.HashKey)))
+			want := map[string]string{
+				"MerchantID":      testMerchant.MerchantId,
+				"Version":         "1.3",
+				"RespondType":     "JSON",
+				"CheckValue":      strings.ToUpper(hex.EncodeToString(hash[:])),
+				"TimeStamp":       "1695795718",
+				"MerchantOrderNo": "ORDER001",
+				"Amt":             "1200",
+				"Gateway":         tt.gateway,
+			}
+			for key, value := range want {
+				if got := srv.postForm.Get(key); got != value {
+					t.Errorf("%s = %q, want %q", key, got, value)
+				}
+			}
+			if tt.gateway == "" && srv.postForm.Has("Gateway") {
+				t.Errorf("Gateway should be omitted, got %q", srv.postForm.Get("Gateway"))
+			}
+			if srv.postForm.Has("TradeNo") {
+				t.Errorf("TradeNo should not be posted, got %q", srv.postForm.Get("TradeNo"))
+			}
+		})
+	}
+}
+
+func TestQueryTradeInfoMissingMerchantOrderNo(t *testing.T) {
+	a := Api{ApiUrlQueryTradeInfo: "http://127.0.0.1:0"}
+	if _, err := a.QueryTradeInfoBy(testMerchant, QueryTradeInfoRequest{Amt: 100}, xtime.NowUTC()); err == nil {
+		t.Fatal("expected error for missing MerchantOrderNo")
+	}
+}
+
+func TestQueryTradeInfoNotFound(t *testing.T) {
+	srv := newFakeNewebPay(t, nil)
+	srv.status = QueryStatusNotFound
+	srv.message = "查無此筆交易"
+	a := Api{ApiUrlQueryTradeInfo: srv.URL}
+
+	_, err := a.QueryTradeInfo(testMerchant, "ORDER001", 1200, xtime.NowUTC())
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) || !queryErr.IsNotFound() {
+		t.Fatalf("err = %v, want QueryError not found", err)
+	}
+}
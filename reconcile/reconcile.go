@@ -0,0 +1,232 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Loopmaas/newebpay"
+)
+
+// 訂單狀態
+type OrderState string
+
+const (
+	StateUnpaid     OrderState = "unpaid"     // 未付款
+	StateFailed     OrderState = "failed"     // 付款失敗
+	StateCancelled  OrderState = "cancelled"  // 取消付款 / 取消授權
+	StateAuthorized OrderState = "authorized" // 授權成功, 未請款
+	StateCapturing  OrderState = "capturing"  // 請款申請中 / 請款處理中
+	StateCaptured   OrderState = "captured"   // 請款完成
+	StateRefunding  OrderState = "refunding"  // 退款申請中 / 退款處理中
+	StateRefunded   OrderState = "refunded"   // 退款完成
+)
+
+// 差異類別
+type Kind string
+
+const (
+	KindMissing           Kind = "missing"             // 藍新金流查無此訂單
+	KindAmountMismatch    Kind = "amount_mismatch"     // 金額不符
+	KindUnexpectedRefund  Kind = "unexpected_refund"   // 非預期的退款
+	KindCaptureNotSettled Kind = "capture_not_settled" // 已請款但尚未完成
+	KindStatusDrift       Kind = "status_drift"        // 狀態不符
+)
+
+// 我方帳上的訂單
+type ExpectedOrder struct {
+	MerchantOrderNo string     `json:"MerchantOrderNo"`
+	Amount          int        `json:"Amount"`
+	State           OrderState `json:"State"`
+	TradeNo         string     `json:"TradeNo,omitempty"` // 藍新金流交易序號 (選填), 比對撥款報表時優先使用
+}
+
+type Discrepancy struct {
+	Kind            Kind       `json:"Kind"`
+	MerchantOrderNo string     `json:"MerchantOrderNo"`
+	TradeNo         string     `json:"TradeNo"`
+	ExpectedAmount  int        `json:"ExpectedAmount"`
+	ActualAmount    int        `json:"ActualAmount"`
+	ExpectedState   OrderState `json:"ExpectedState"`
+	ActualState     OrderState `json:"ActualState"`
+	Detail          string     `json:"Detail"`
+}
+
+// 查詢失敗 (網路錯誤, 回應格式錯誤等), 無法判斷是否有差異
+type Failure struct {
+	MerchantOrderNo string `json:"MerchantOrderNo"`
+	Error           string `json:"Error"`
+}
+
+type Querier interface {
+	BulkQueryTradeInfo(ctx context.Context, m *newebpay.Merchant, reqs []newebpay.QueryTradeInfoRequest, opts newebpay.BulkQueryOptions) []newebpay.BulkQueryResult
+}
+
+var _ Querier = newebpay.Api{}
+
+type Reconciler struct {
+	Querier   Querier
+	Merchant  *newebpay.Merchant
+	Gateway   string                    // 空值=一般商店, Composite=合作推廣商/平台型商店
+	Options   newebpay.BulkQueryOptions // 查詢的 worker 數量及頻率限制
+	BatchSize int                       // 每批查詢的訂單數, 預設 100
+}
+
+// 依序讀取 orders 直到 channel 關閉, 分批查詢藍新金流並比對差異
+func (r Reconciler) Run(ctx context.Context, orders <-chan ExpectedOrder) (*Report, error) {
+	batchSize := r.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	report := Report{
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	batch := make([]ExpectedOrder, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		r.reconcile(ctx, batch, &report)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			return &report, ctx.Err()
+		case order, ok := <-orders:
+			if !ok {
+				flush()
+				return &report, nil
+			}
+
+			batch = append(batch, order)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		}
+	}
+}
+
+func (r Reconciler) reconcile(ctx context.Context, orders []ExpectedOrder, report *Report) {
+	reqs := make([]newebpay.QueryTradeInfoRequest, len(orders))
+	for i, order := range orders {
+		reqs[i] = newebpay.QueryTradeInfoRequest{
+			MerchantOrderNo: order.MerchantOrderNo,
+			Amt:             order.Amount,
+			Gateway:         r.Gateway,
+		}
+	}
+
+	results := r.Querier.BulkQueryTradeInfo(ctx, r.Merchant, reqs, r.Options)
+	for i, result := range results {
+		order := orders[i]
+		report.Checked++
+
+		// 查詢時帶入帳上金額, 金額不符時藍新金流直接拒絕查詢, 不會回傳交易資料
+		var queryErr *newebpay.QueryError
+		switch {
+		case errors.As(result.Err, &queryErr) && queryErr.IsNotFound():
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				Kind:            KindMissing,
+				MerchantOrderNo: order.MerchantOrderNo,
+				ExpectedAmount:  order.Amount,
+				ExpectedState:   order.State,
+				Detail:          queryErr.Error(),
+			})
+		case errors.As(result.Err, &queryErr) && queryErr.IsAmountMismatch():
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				Kind:            KindAmountMismatch,
+				MerchantOrderNo: order.MerchantOrderNo,
+				ExpectedAmount:  order.Amount,
+				ExpectedState:   order.State,
+				Detail:          queryErr.Error(),
+			})
+		case result.Err != nil:
+			report.Failures = append(report.Failures, Failure{
+				MerchantOrderNo: order.MerchantOrderNo,
+				Error:           result.Err.Error(),
+			})
+		default:
+			discrepancies := Compare(order, &result.Resp.Result)
+			if len(discrepancies) == 0 {
+				report.Matched++
+			}
+			report.Discrepancies = append(report.Discrepancies, discrepancies...)
+		}
+	}
+}
+
+// 藍新金流交易的目前狀態
+func StateOf(r *newebpay.ResultQueryTradeInfo) OrderState {
+	switch r.TradeStatus {
+	case "0":
+		return StateUnpaid
+	case "2":
+		return StateFailed
+	case "3":
+		return StateCancelled
+	case "6":
+		return StateRefunded
+	}
+
+	switch r.BackStatus {
+	case "1", "2":
+		return StateRefunding
+	case "3":
+		return StateRefunded
+	}
+
+	switch r.CloseStatus {
+	case "1", "2":
+		return StateCapturing
+	case "3":
+		return StateCaptured
+	}
+
+	return StateAuthorized
+}
+
+func Compare(order ExpectedOrder, r *newebpay.ResultQueryTradeInfo) []Discrepancy {
+	actual := StateOf(r)
+	newDiscrepancy := func(kind Kind, actualAmount int, detail string) Discrepancy {
+		return Discrepancy{
+			Kind:            kind,
+			MerchantOrderNo: order.MerchantOrderNo,
+			TradeNo:         r.TradeNo,
+			ExpectedAmount:  order.Amount,
+			ActualAmount:    actualAmount,
+			ExpectedState:   order.State,
+			ActualState:     actual,
+			Detail:          detail,
+		}
+	}
+
+	var discrepancies []Discrepancy
+	if r.Amt != order.Amount {
+		discrepancies = append(discrepancies, newDiscrepancy(KindAmountMismatch, r.Amt, "Amt"))
+	}
+
+	if order.State == StateCaptured && actual == StateCaptured {
+		if closeAmt, err := strconv.Atoi(r.CloseAmt); err == nil && closeAmt != order.Amount {
+			discrepancies = append(discrepancies, newDiscrepancy(KindAmountMismatch, closeAmt, "CloseAmt"))
+		}
+	}
+
+	switch {
+	case actual == order.State:
+	case (actual == StateRefunding || actual == StateRefunded) && order.State != StateRefunding && order.State != StateRefunded:
+		discrepancies = append(discrepancies, newDiscrepancy(KindUnexpectedRefund, r.Amt, fmt.Sprintf("BackStatus: %s, BackBalance: %s", r.BackStatus, r.BackBalance)))
+	case order.State == StateCaptured && actual == StateCapturing:
+		discrepancies = append(discrepancies, newDiscrepancy(KindCaptureNotSettled, r.Amt, fmt.Sprintf("CloseStatus: %s", r.CloseStatus)))
+	default:
+		discrepancies = append(discrepancies, newDiscrepancy(KindStatusDrift, r.Amt, fmt.Sprintf("TradeStatus: %s, CloseStatus: %s, BackStatus: %s", r.TradeStatus, r.CloseStatus, r.BackStatus)))
+	}
+
+	return discrepancies
+}
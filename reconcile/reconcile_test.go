@@ -0,0 +1,143 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Loopmaas/newebpay"
+)
+
+type fakeQuerier struct {
+	results map[string]newebpay.BulkQueryResult
+}
+
+func (q fakeQuerier) BulkQueryTradeInfo(ctx context.Context, m *newebpay.Merchant, reqs []newebpay.QueryTradeInfoRequest, opts newebpay.BulkQueryOptions) []newebpay.BulkQueryResult {
+	results := make([]newebpay.BulkQueryResult, len(reqs))
+	for i, req := range reqs {
+		results[i] = q.results[req.MerchantOrderNo]
+		results[i].Request = req
+	}
+	return results
+}
+
+func tradeResult(amt int, tradeStatus, closeStatus, closeAmt, backStatus string) *newebpay.RespQueryTradeInfo {
+	return &newebpay.RespQueryTradeInfo{
+		Status: "SUCCESS",
+		Result: newebpay.ResultQueryTradeInfo{
+			Amt:         amt,
+			TradeNo:     "T1",
+			TradeStatus: tradeStatus,
+			CloseStatus: closeStatus,
+			CloseAmt:    closeAmt,
+			BackStatus:  backStatus,
+		},
+	}
+}
+
+func TestStateOf(t *testing.T) {
+	tests := []struct {
+		name                                 string
+		tradeStatus, closeStatus, backStatus string
+		want                                 OrderState
+	}{
+		{"unpaid", "0", "", "", StateUnpaid},
+		{"failed", "2", "", "", StateFailed},
+		{"cancelled", "3", "", "", StateCancelled},
+		{"refunded trade", "6", "", "", StateRefunded},
+		{"authorized", "1", "0", "0", StateAuthorized},
+		{"capturing", "1", "2", "0", StateCapturing},
+		{"captured", "1", "3", "0", StateCaptured},
+		{"refunding", "1", "3", "1", StateRefunding},
+		{"refunded", "1", "3", "3", StateRefunded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newebpay.ResultQueryTradeInfo{TradeStatus: tt.tradeStatus, CloseStatus: tt.closeStatus, BackStatus: tt.backStatus}
+			if got := StateOf(&r); got != tt.want {
+				t.Errorf("StateOf() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name  string
+		order ExpectedOrder
+		resp  *newebpay.RespQueryTradeInfo
+		want  []Kind
+	}{
+		{"match", ExpectedOrder{Amount: 100, State: StateCaptured}, tradeResult(100, "1", "3", "100", "0"), nil},
+		{"amount mismatch", ExpectedOrder{Amount: 100, State: StateAuthorized}, tradeResult(90, "1", "0", "", "0"), []Kind{KindAmountMismatch}},
+		{"close amount mismatch", ExpectedOrder{Amount: 100, State: StateCaptured}, tradeResult(100, "1", "3", "80", "0"), []Kind{KindAmountMismatch}},
+		{"unexpected refund", ExpectedOrder{Amount: 100, State: StateCaptured}, tradeResult(100, "1", "3", "100", "3"), []Kind{KindUnexpectedRefund}},
+		{"capture not settled", ExpectedOrder{Amount: 100, State: StateCaptured}, tradeResult(100, "1", "2", "100", "0"), []Kind{KindCaptureNotSettled}},
+		{"status drift", ExpectedOrder{Amount: 100, State: StateCaptured}, tradeResult(100, "3", "", "", ""), []Kind{KindStatusDrift}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compare(tt.order, &tt.resp.Result)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Compare() = %+v, want kinds %v", got, tt.want)
+			}
+			for i, d := range got {
+				if d.Kind != tt.want[i] {
+					t.Errorf("Compare()[%d].Kind = %s, want %s", i, d.Kind, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReconcilerRun(t *testing.T) {
+	querier := fakeQuerier{results: map[string]newebpay.BulkQueryResult{
+		"OK":       {Resp: tradeResult(100, "1", "3", "100", "0")},
+		"MISSING":  {Err: &newebpay.QueryError{Status: newebpay.QueryStatusNotFound, Message: "查無此筆交易"}},
+		"AMOUNT":   {Err: &newebpay.QueryError{Status: newebpay.QueryStatusAmountMismatch, Message: "金額不符"}},
+		"AUTH":     {Err: &newebpay.QueryError{Status: "TRA10002", Message: "檢查碼錯誤"}},
+		"NETWORK":  {Err: errors.New("connection reset")},
+		"REFUNDED": {Resp: tradeResult(100, "1", "3", "100", "3")},
+	}}
+
+	orders := make(chan ExpectedOrder)
+	go func() {
+		defer close(orders)
+		for _, no := range []string{"OK", "MISSING", "AMOUNT", "AUTH", "NETWORK", "REFUNDED"} {
+			orders <- ExpectedOrder{MerchantOrderNo: no, Amount: 100, State: StateCaptured}
+		}
+	}()
+
+	report, err := Reconciler{Querier: querier, BatchSize: 4}.Run(context.Background(), orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Checked != 6 || report.Matched != 1 {
+		t.Errorf("Checked = %d, Matched = %d", report.Checked, report.Matched)
+	}
+
+	kinds := map[string]Kind{}
+	for _, d := range report.Discrepancies {
+		kinds[d.MerchantOrderNo] = d.Kind
+	}
+	want := map[string]Kind{"MISSING": KindMissing, "AMOUNT": KindAmountMismatch, "REFUNDED": KindUnexpectedRefund}
+	for no, kind := range want {
+		if kinds[no] != kind {
+			t.Errorf("discrepancy of %s = %s, want %s", no, kinds[no], kind)
+		}
+	}
+	if len(report.Discrepancies) != len(want) {
+		t.Errorf("Discrepancies = %+v", report.Discrepancies)
+	}
+
+	failed := map[string]bool{}
+	for _, f := range report.Failures {
+		failed[f.MerchantOrderNo] = true
+	}
+	if len(report.Failures) != 2 || !failed["AUTH"] || !failed["NETWORK"] {
+		t.Errorf("Failures = %+v", report.Failures)
+	}
+}
@@ -0,0 +1,77 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+type Report struct {
+	GeneratedAt   time.Time     `json:"GeneratedAt"`
+	Checked       int           `json:"Checked"` // 已比對的訂單數
+	Matched       int           `json:"Matched"` // 無差異的訂單數
+	Discrepancies []Discrepancy `json:"Discrepancies"`
+	Failures      []Failure     `json:"Failures"`
+}
+
+func (r Report) ByKind(kind Kind) []Discrepancy {
+	var discrepancies []Discrepancy
+	for _, d := range r.Discrepancies {
+		if d.Kind == kind {
+			discrepancies = append(discrepancies, d)
+		}
+	}
+	return discrepancies
+}
+
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+var csvHeader = []string{
+	"Kind",
+	"MerchantOrderNo",
+	"TradeNo",
+	"ExpectedAmount",
+	"ActualAmount",
+	"ExpectedState",
+	"ActualState",
+	"Detail",
+}
+
+// 每筆差異一列, 查詢失敗的訂單以 Kind=error 輸出
+func (r Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, d := range r.Discrepancies {
+		record := []string{
+			string(d.Kind),
+			d.MerchantOrderNo,
+			d.TradeNo,
+			strconv.Itoa(d.ExpectedAmount),
+			strconv.Itoa(d.ActualAmount),
+			string(d.ExpectedState),
+			string(d.ActualState),
+			d.Detail,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range r.Failures {
+		if err := cw.Write([]string{"error", f.MerchantOrderNo, "", "", "", "", "", f.Error}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,353 @@
+package reconcile
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 藍新金流後台匯出的撥款明細/交易明細報表中的一筆交易
+type ReportRecord struct {
+	MerchantID      string    `json:"MerchantID"`      // 商店代號
+	MerchantOrderNo string    `json:"MerchantOrderNo"` // 商店訂單編號
+	TradeNo         string    `json:"TradeNo"`         // 藍新金流交易序號
+	PaymentType     string    `json:"PaymentType"`     // 支付方式, CREDIT
+	TradeAmt        int       `json:"TradeAmt"`        // 交易金額
+	Fee             int       `json:"Fee"`             // 手續費
+	PayoutAmt       int       `json:"PayoutAmt"`       // 撥款金額
+	TradeTime       time.Time `json:"TradeTime"`       // 交易時間
+	PayoutDate      time.Time `json:"PayoutDate"`      // 撥款日期, 交易明細報表無此欄位
+}
+
+// 報表欄位
+type ReportField string
+
+const (
+	FieldMerchantID      ReportField = "MerchantID"
+	FieldMerchantOrderNo ReportField = "MerchantOrderNo"
+	FieldTradeNo         ReportField = "TradeNo"
+	FieldPaymentType     ReportField = "PaymentType"
+	FieldTradeAmt        ReportField = "TradeAmt"
+	FieldFee             ReportField = "Fee"
+	FieldPayoutAmt       ReportField = "PayoutAmt"
+	FieldTradeTime       ReportField = "TradeTime"
+	FieldPayoutDate      ReportField = "PayoutDate"
+)
+
+// 報表標題 => 欄位, 後台報表格式調整時可自行增加對應
+var DefaultReportColumns = map[string]ReportField{
+	"商店代號":     FieldMerchantID,
+	"商店訂單編號":   FieldMerchantOrderNo,
+	"藍新金流交易序號": FieldTradeNo,
+	"交易序號":     FieldTradeNo,
+	"支付方式":     FieldPaymentType,
+	"付款方式":     FieldPaymentType,
+	"交易金額":     FieldTradeAmt,
+	"訂單金額":     FieldTradeAmt,
+	"手續費":      FieldFee,
+	"交易手續費":    FieldFee,
+	"撥款金額":     FieldPayoutAmt,
+	"實撥金額":     FieldPayoutAmt,
+	"交易時間":     FieldTradeTime,
+	"交易日期":     FieldTradeTime,
+	"撥款日期":     FieldPayoutDate,
+	"預計撥款日":    FieldPayoutDate,
+}
+
+type ReportParser struct {
+	Columns  map[string]ReportField // 預設 DefaultReportColumns
+	Location *time.Location         // 報表時間的時區, 預設 Asia/Taipei
+	Sheet    string                 // xlsx 工作表名稱, 預設為第一個有資料的工作表
+}
+
+func (p ReportParser) ParseCSV(r io.Reader) ([]ReportRecord, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))))
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("[report] %w", err)
+	}
+
+	return p.parseRows(rows)
+}
+
+func (p ReportParser) ParseXLSX(r io.ReaderAt, size int64) ([]ReportRecord, error) {
+	rows, err := readXLSXRows(r, size, p.Sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.parseRows(rows)
+}
+
+func (p ReportParser) parseRows(rows [][]string) ([]ReportRecord, error) {
+	columns := p.Columns
+	if columns == nil {
+		columns = DefaultReportColumns
+	}
+
+	location := p.Location
+	if location == nil {
+		var err error
+		if location, err = time.LoadLocation("Asia/Taipei"); err != nil {
+			return nil, err
+		}
+	}
+
+	// 報表開頭可能有查詢條件等說明列, 以第一個包含商店訂單編號或交易序號的列作為標題
+	headerIdx := -1
+	var fields map[int]ReportField
+	for i, row := range rows {
+		fields = map[int]ReportField{}
+		for col, title := range row {
+			if field, ok := columns[strings.TrimSpace(title)]; ok {
+				fields[col] = field
+			}
+		}
+
+		if containsField(fields, FieldMerchantOrderNo) || containsField(fields, FieldTradeNo) {
+			headerIdx = i
+			break
+		}
+	}
+	if headerIdx < 0 {
+		return nil, errors.New("[report] header not found")
+	}
+
+	var records []ReportRecord
+	for i, row := range rows[headerIdx+1:] {
+		if isBlankRow(row) {
+			continue
+		}
+
+		var record ReportRecord
+		for col, field := range fields {
+			if col >= len(row) {
+				continue
+			}
+
+			if err := record.set(field, strings.TrimSpace(row[col]), location); err != nil {
+				return nil, fmt.Errorf("[report] row %d: %w", headerIdx+i+2, err)
+			}
+		}
+
+		// 合計列等沒有單號的資料
+		if record.MerchantOrderNo == "" && record.TradeNo == "" {
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (r *ReportRecord) set(field ReportField, value string, location *time.Location) error {
+	var err error
+	switch field {
+	case FieldMerchantID:
+		r.MerchantID = value
+	case FieldMerchantOrderNo:
+		r.MerchantOrderNo = value
+	case FieldTradeNo:
+		r.TradeNo = value
+	case FieldPaymentType:
+		r.PaymentType = value
+	case FieldTradeAmt:
+		r.TradeAmt, err = parseReportAmount(value)
+	case FieldFee:
+		r.Fee, err = parseReportAmount(value)
+	case FieldPayoutAmt:
+		r.PayoutAmt, err = parseReportAmount(value)
+	case FieldTradeTime:
+		r.TradeTime, err = parseReportTime(value, location)
+	case FieldPayoutDate:
+		r.PayoutDate, err = parseReportTime(value, location)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", field, err)
+	}
+	return nil
+}
+
+func containsField(fields map[int]ReportField, field ReportField) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlankRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// 1,234 / 1234.00 / -30
+func parseReportAmount(value string) (int, error) {
+	value = strings.ReplaceAll(value, ",", "")
+	if value == "" {
+		return 0, nil
+	}
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", value)
+	}
+	return int(math.Round(amount)), nil
+}
+
+var reportTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"2006/1/2 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+	"2006/1/2",
+	"20060102",
+}
+
+func parseReportTime(value string, location *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range reportTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+
+	// Excel 日期序號, 以 1899-12-30 起算的天數
+	if serial, err := strconv.ParseFloat(value, 64); err == nil && serial > 0 {
+		epoch := time.Date(1899, time.December, 30, 0, 0, 0, 0, location)
+		return epoch.Add(time.Duration(serial * float64(24*time.Hour))).Round(time.Second), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time: %s", value)
+}
+
+// 解析 RequestAddMerchant.AgreedFee, 格式為 [支付方式代號:費率], 多筆以 "|" 分隔, 例如 CREDIT:0.02|WEBATM:0.01
+func ParseAgreedFee(agreedFee string) (map[string]float64, error) {
+	fees := map[string]float64{}
+	for _, item := range strings.Split(agreedFee, "|") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		paymentType, rate, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("[report] invalid AgreedFee: %s", item)
+		}
+
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			return nil, fmt.Errorf("[report] invalid AgreedFee rate: %s", item)
+		}
+		fees[strings.ToUpper(paymentType)] = r
+	}
+
+	return fees, nil
+}
+
+type FeeMismatch struct {
+	Record      ReportRecord `json:"Record"`
+	Rate        float64      `json:"Rate"`        // 約定費率
+	ExpectedFee int          `json:"ExpectedFee"` // 依約定費率計算的手續費
+}
+
+// 依約定費率檢查報表手續費, tolerance 為可容許的四捨五入差額
+func VerifyFees(records []ReportRecord, agreedFee map[string]float64, tolerance int) []FeeMismatch {
+	var mismatches []FeeMismatch
+	for _, record := range records {
+		rate, ok := agreedFee[strings.ToUpper(record.PaymentType)]
+		if !ok && len(agreedFee) == 1 && record.PaymentType == "" {
+			for _, r := range agreedFee {
+				rate, ok = r, true
+			}
+		}
+
+		expected := int(math.Round(float64(record.TradeAmt) * rate))
+		diff := record.Fee - expected
+		if !ok || diff > tolerance || diff < -tolerance {
+			mismatches = append(mismatches, FeeMismatch{
+				Record:      record,
+				Rate:        rate,
+				ExpectedFee: expected,
+			})
+		}
+	}
+
+	return mismatches
+}
+
+type SettlementMatch struct {
+	Order  ExpectedOrder `json:"Order"`
+	Record ReportRecord  `json:"Record"`
+}
+
+type SettlementResult struct {
+	Matched          []SettlementMatch `json:"Matched"`
+	AmountMismatches []SettlementMatch `json:"AmountMismatches"` // 報表交易金額與訂單金額不符
+	UnmatchedRecords []ReportRecord    `json:"UnmatchedRecords"` // 報表中有但訂單中沒有
+	UnsettledOrders  []ExpectedOrder   `json:"UnsettledOrders"`  // 訂單中有但報表中沒有
+}
+
+// 以 TradeNo 優先, 其次 MerchantOrderNo 比對報表與訂單
+func MatchSettlement(records []ReportRecord, orders []ExpectedOrder) SettlementResult {
+	byTradeNo := map[string]int{}
+	byOrderNo := map[string]int{}
+	for i, order := range orders {
+		if order.TradeNo != "" {
+			byTradeNo[order.TradeNo] = i
+		}
+		byOrderNo[order.MerchantOrderNo] = i
+	}
+
+	var result SettlementResult
+	matched := make([]bool, len(orders))
+	for _, record := range records {
+		i, ok := byTradeNo[record.TradeNo]
+		if !ok || record.TradeNo == "" {
+			i, ok = byOrderNo[record.MerchantOrderNo]
+		}
+		if !ok {
+			result.UnmatchedRecords = append(result.UnmatchedRecords, record)
+			continue
+		}
+
+		matched[i] = true
+		m := SettlementMatch{Order: orders[i], Record: record}
+		if record.TradeAmt != orders[i].Amount {
+			result.AmountMismatches = append(result.AmountMismatches, m)
+		} else {
+			result.Matched = append(result.Matched, m)
+		}
+	}
+
+	for i, order := range orders {
+		if !matched[i] {
+			result.UnsettledOrders = append(result.UnsettledOrders, order)
+		}
+	}
+
+	return result
+}
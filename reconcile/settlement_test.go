@@ -0,0 +1,223 @@
+package reconcile
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testReportCSV = "\xef\xbb\xbf查詢期間,2024/11/01~2024/11/30\n" +
+	"商店代號,商店訂單編號,藍新金流交易序號,支付方式,交易金額,手續費,撥款金額,交易時間,撥款日期\n" +
+	"MS1,ORDER1,T1,CREDIT,\"1,000\",20,980,2024/11/01 10:00:00,2024/11/04\n" +
+	",,,,,,,,\n" +
+	"MS1,ORDER2,T2,WEBATM,500.00,5,495,2024-11-02 11:30:00,20241105\n" +
+	"合計,,,,1500,25,1475,,\n"
+
+func TestParseCSV(t *testing.T) {
+	records, err := ReportParser{}.ParseCSV(strings.NewReader(testReportCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("records = %+v", records)
+	}
+
+	location, _ := time.LoadLocation("Asia/Taipei")
+	first := records[0]
+	if first.MerchantOrderNo != "ORDER1" || first.TradeNo != "T1" || first.TradeAmt != 1000 || first.Fee != 20 || first.PayoutAmt != 980 {
+		t.Errorf("records[0] = %+v", first)
+	}
+	if !first.TradeTime.Equal(time.Date(2024, 11, 1, 10, 0, 0, 0, location)) {
+		t.Errorf("records[0].TradeTime = %v", first.TradeTime)
+	}
+	if !records[1].PayoutDate.Equal(time.Date(2024, 11, 5, 0, 0, 0, 0, location)) {
+		t.Errorf("records[1].PayoutDate = %v", records[1].PayoutDate)
+	}
+}
+
+func TestParseCSVInvalid(t *testing.T) {
+	if _, err := (ReportParser{}).ParseCSV(strings.NewReader("a,b\n1,2\n")); err == nil {
+		t.Error("expected header not found error")
+	}
+
+	csv := "商店訂單編號,交易金額\nORDER1,abc\n"
+	if _, err := (ReportParser{}).ParseCSV(strings.NewReader(csv)); err == nil {
+		t.Error("expected invalid amount error")
+	}
+}
+
+type testXLSXSheet struct {
+	name string
+	xml  string
+}
+
+func buildTestXLSX(t *testing.T, sharedStrings string, sheets []testXLSXSheet, withWorkbook bool) *bytes.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	write := func(name, content string) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if sharedStrings != "" {
+		write("xl/sharedStrings.xml", sharedStrings)
+	}
+
+	var workbook, rels strings.Builder
+	workbook.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i, sheet := range sheets {
+		id := "rId" + string(rune('1'+i))
+		// 刻意不使用 sheet1.xml, 確認是由 workbook.xml 取得工作表路徑
+		file := "data" + string(rune('1'+i)) + ".xml"
+		workbook.WriteString(`<sheet name="` + sheet.name + `" sheetId="` + string(rune('1'+i)) + `" r:id="` + id + `"/>`)
+		rels.WriteString(`<Relationship Id="` + id + `" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/` + file + `"/>`)
+		if !withWorkbook {
+			file = "sheet1.xml"
+		}
+		write("xl/worksheets/"+file, sheet.xml)
+	}
+	workbook.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+	if withWorkbook {
+		write("xl/workbook.xml", workbook.String())
+		write("xl/_rels/workbook.xml.rels", rels.String())
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+const testSharedStrings = `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<si><t>商店訂單編號</t></si><si><t>交易金額</t></si><si><r><t>ORDER</t></r><r><t>1</t></r></si></sst>`
+
+const testDataSheet = `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+	`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>` +
+	`<row r="2"><c r="A2" t="s"><v>2</v></c><c r="C2"><v>1200</v></c></row>` +
+	`<row r="3"><c r="A3" t="inlineStr"><is><t>ORDER2</t></is></c><c r="C3"><v>300</v></c></row>` +
+	`</sheetData></worksheet>`
+
+const testEmptySheet = `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData/></worksheet>`
+
+func TestParseXLSX(t *testing.T) {
+	tests := []struct {
+		name         string
+		sheets       []testXLSXSheet
+		withWorkbook bool
+		sheet        string
+	}{
+		{"sheet1 only", []testXLSXSheet{{"Sheet1", testDataSheet}}, false, ""},
+		{"data on second sheet", []testXLSXSheet{{"說明", testEmptySheet}, {"明細", testDataSheet}}, true, ""},
+		{"named sheet", []testXLSXSheet{{"說明", testEmptySheet}, {"明細", testDataSheet}}, true, "明細"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := buildTestXLSX(t, testSharedStrings, tt.sheets, tt.withWorkbook)
+			records, err := ReportParser{Sheet: tt.sheet}.ParseXLSX(r, r.Size())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(records) != 2 || records[0].MerchantOrderNo != "ORDER1" || records[0].TradeAmt != 1200 ||
+				records[1].MerchantOrderNo != "ORDER2" || records[1].TradeAmt != 300 {
+				t.Errorf("records = %+v", records)
+			}
+		})
+	}
+}
+
+func TestParseXLSXInvalid(t *testing.T) {
+	badRef := `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		`<row r="1"><c r="1" t="s"><v>0</v></c></row></sheetData></worksheet>`
+	farRef := `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		`<row r="1"><c r="ZZZZZZ1" t="s"><v>0</v></c></row></sheetData></worksheet>`
+	badIndex := `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		`<row r="1"><c r="A1" t="s"><v>9</v></c></row></sheetData></worksheet>`
+
+	tests := []struct {
+		name   string
+		sheets []testXLSXSheet
+		sheet  string
+	}{
+		{"cell reference without column", []testXLSXSheet{{"Sheet1", badRef}}, ""},
+		{"cell reference past XFD", []testXLSXSheet{{"Sheet1", farRef}}, ""},
+		{"shared string index out of range", []testXLSXSheet{{"Sheet1", badIndex}}, ""},
+		{"sheet not found", []testXLSXSheet{{"Sheet1", testDataSheet}}, "明細"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := buildTestXLSX(t, testSharedStrings, tt.sheets, true)
+			if _, err := (ReportParser{Sheet: tt.sheet}).ParseXLSX(r, r.Size()); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestXLSXColumnIndex(t *testing.T) {
+	tests := map[string]int{"A1": 0, "Z9": 25, "AB12": 27, "XFD1": 16383, "XFE1": -1, "ZZZZZZZZZZZZZZZ1": -1, "1": -1, "": -1}
+	for ref, want := range tests {
+		if got := xlsxColumnIndex(ref); got != want {
+			t.Errorf("xlsxColumnIndex(%q) = %d, want %d", ref, got, want)
+		}
+	}
+}
+
+func TestVerifyFees(t *testing.T) {
+	records := []ReportRecord{
+		{MerchantOrderNo: "A", PaymentType: "CREDIT", TradeAmt: 1000, Fee: 20},
+		{MerchantOrderNo: "B", PaymentType: "CREDIT", TradeAmt: 1000, Fee: 30},
+		{MerchantOrderNo: "C", PaymentType: "VACC", TradeAmt: 1000, Fee: 10},
+	}
+
+	fees, err := ParseAgreedFee("CREDIT:0.02")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches := VerifyFees(records, fees, 1)
+	if len(mismatches) != 2 || mismatches[0].Record.MerchantOrderNo != "B" || mismatches[1].Record.MerchantOrderNo != "C" {
+		t.Errorf("mismatches = %+v", mismatches)
+	}
+}
+
+func TestMatchSettlement(t *testing.T) {
+	records := []ReportRecord{
+		{MerchantOrderNo: "A", TradeNo: "T1", TradeAmt: 100},
+		{MerchantOrderNo: "B", TradeAmt: 90},
+		{MerchantOrderNo: "X", TradeAmt: 10},
+	}
+	orders := []ExpectedOrder{
+		{MerchantOrderNo: "A-renamed", TradeNo: "T1", Amount: 100},
+		{MerchantOrderNo: "B", Amount: 100},
+		{MerchantOrderNo: "C", Amount: 50},
+	}
+
+	result := MatchSettlement(records, orders)
+	if len(result.Matched) != 1 || result.Matched[0].Order.TradeNo != "T1" {
+		t.Errorf("Matched = %+v", result.Matched)
+	}
+	if len(result.AmountMismatches) != 1 || result.AmountMismatches[0].Order.MerchantOrderNo != "B" {
+		t.Errorf("AmountMismatches = %+v", result.AmountMismatches)
+	}
+	if len(result.UnmatchedRecords) != 1 || result.UnmatchedRecords[0].MerchantOrderNo != "X" {
+		t.Errorf("UnmatchedRecords = %+v", result.UnmatchedRecords)
+	}
+	if len(result.UnsettledOrders) != 1 || result.UnsettledOrders[0].MerchantOrderNo != "C" {
+		t.Errorf("UnsettledOrders = %+v", result.UnsettledOrders)
+	}
+}
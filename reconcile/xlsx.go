@@ -0,0 +1,230 @@
+package reconcile
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type xlsxSharedStrings struct {
+	Items []struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline struct {
+				Text string `xml:"t"`
+			} `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// 工作表名稱及 zip 內的路徑, 依活頁簿中的順序
+type xlsxSheetFile struct {
+	Name string
+	Path string
+}
+
+// 由 xl/workbook.xml 及 xl/_rels/workbook.xml.rels 取得工作表路徑, 缺少時視為只有 sheet1.xml
+func xlsxSheetFiles(zr *zip.Reader) ([]xlsxSheetFile, error) {
+	wf := findZipFile(zr, "xl/workbook.xml")
+	rf := findZipFile(zr, "xl/_rels/workbook.xml.rels")
+	if wf == nil || rf == nil {
+		return []xlsxSheetFile{{Name: "Sheet1", Path: "xl/worksheets/sheet1.xml"}}, nil
+	}
+
+	var workbook xlsxWorkbook
+	if err := decodeZipXML(wf, &workbook); err != nil {
+		return nil, err
+	}
+
+	var rels xlsxRelationships
+	if err := decodeZipXML(rf, &rels); err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		targets[rel.ID] = rel.Target
+	}
+
+	sheets := make([]xlsxSheetFile, 0, len(workbook.Sheets))
+	for _, sheet := range workbook.Sheets {
+		target, ok := targets[sheet.RID]
+		if !ok {
+			return nil, fmt.Errorf("[xlsx] missing relationship of sheet %s: %s", sheet.Name, sheet.RID)
+		}
+
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		sheets = append(sheets, xlsxSheetFile{Name: sheet.Name, Path: target})
+	}
+	return sheets, nil
+}
+
+// 讀取 xlsx 工作表的所有儲存格, 僅支援後台匯出報表所需的字串及數值欄位。
+// sheetName 空值時讀取活頁簿中第一個有資料的工作表
+func readXLSXRows(r io.ReaderAt, size int64, sheetName string) ([][]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("[xlsx] %w", err)
+	}
+
+	var shared []string
+	if f := findZipFile(zr, "xl/sharedStrings.xml"); f != nil {
+		var ss xlsxSharedStrings
+		if err := decodeZipXML(f, &ss); err != nil {
+			return nil, err
+		}
+
+		shared = make([]string, len(ss.Items))
+		for i, item := range ss.Items {
+			text := item.Text
+			for _, run := range item.Runs {
+				text += run.Text
+			}
+			shared[i] = text
+		}
+	}
+
+	sheetFiles, err := xlsxSheetFiles(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	for _, sheetFile := range sheetFiles {
+		if sheetName != "" && sheetFile.Name != sheetName {
+			continue
+		}
+		found = true
+
+		f := findZipFile(zr, sheetFile.Path)
+		if f == nil {
+			return nil, fmt.Errorf("[xlsx] missing %s", sheetFile.Path)
+		}
+
+		rows, err := readXLSXSheet(f, shared)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) > 0 || sheetName != "" {
+			return rows, nil
+		}
+	}
+
+	if sheetName != "" && !found {
+		return nil, fmt.Errorf("[xlsx] sheet not found: %s", sheetName)
+	}
+	return nil, nil
+}
+
+func readXLSXSheet(f *zip.File, shared []string) ([][]string, error) {
+	var sheet xlsxSheet
+	if err := decodeZipXML(f, &sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		var values []string
+		for i, cell := range row.Cells {
+			col := i
+			if cell.Ref != "" {
+				col = xlsxColumnIndex(cell.Ref)
+				if col < 0 {
+					return nil, fmt.Errorf("[xlsx] %s: invalid cell reference: %s", f.Name, cell.Ref)
+				}
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+
+			switch cell.Type {
+			case "s":
+				idx, err := strconv.Atoi(cell.Value)
+				if err != nil || idx < 0 || idx >= len(shared) {
+					return nil, fmt.Errorf("[xlsx] invalid shared string index: %s", cell.Value)
+				}
+				values[col] = shared[idx]
+			case "inlineStr":
+				values[col] = cell.Inline.Text
+			default:
+				values[col] = cell.Value
+			}
+		}
+		rows = append(rows, values)
+	}
+
+	return rows, nil
+}
+
+func findZipFile(zr *zip.Reader, name string) *zip.File {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func decodeZipXML(f *zip.File, v any) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("[xlsx] %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("[xlsx] %s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// Excel 最多 16384 欄 (A~XFD)
+const xlsxMaxColumns = 16384
+
+// A1 => 0, AB12 => 27, 無欄位字母或超過 XFD 時為 -1
+func xlsxColumnIndex(ref string) int {
+	col := 0
+	for _, c := range strings.ToUpper(ref) {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		col = col*26 + int(c-'A'+1)
+		if col > xlsxMaxColumns {
+			return -1
+		}
+	}
+	return col - 1
+}
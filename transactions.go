@@ -29,7 +29,7 @@ type TransactionPostData struct {
 	Amt             int    `json:"Amt"`             // 訂單金額
 	ProdDesc        string `json:"ProdDesc"`        // 商品描述: len 50
 	PayerEmail      string `json:"PayerEmail"`      // 付款人電子信箱
-	Inst            string `json:"Inst"`            // 信用卡分期付款啟用: 此欄位值=0或無值時，即代表不開啟分期
+	Inst            string `json:"Inst"`            // 信用卡分期付款啟用: 此欄位值=0或無值時，即代表不開啟分期, 3,6,12,18,24,30=分期期數
 	TokenValue      string `json:"TokenValue"`      // 約定 Token: 為首次約定付款 (P1) 成功時，所回傳之 TokenValue 值
 	TokenTerm       string `json:"TokenTerm"`       // Token 名稱: 為首次約定付款 (P1) 時，所使用之 TokenTerm 值
 	TokenSwitch     string `json:"TokenSwitch"`     // Token 類別: on
@@ -166,7 +166,12 @@ func (r ResultTransaction) TransactedAt() (xtime.Time, error) {
 	return xtime.Time(parsedTime.UTC()), nil
 }
 
+// 未回傳 CheckCode 時回傳 ErrCheckCodeMissing, 是否視為通過由 Api.StrictCheckCode 決定
 func (r ResultTransaction) VerifyCheckCode(hashKey, hashIv string) (bool, error) {
+	if r.CheckCode == "" {
+		return false, ErrCheckCodeMissing
+	}
+
 	checkCode, err := genCheckCode(r.Amt, r.MerchantID, r.MerchantOrderNo, r.TradeNo, hashKey, hashIv)
 	if err != nil {
 		return false, err
@@ -175,6 +180,14 @@ func (r ResultTransaction) VerifyCheckCode(hashKey, hashIv string) (bool, error)
 	return (checkCode == r.CheckCode), nil
 }
 
+func (r ResultTransaction) Installment() *InstallmentSchedule {
+	return newInstallmentSchedule(r.Inst, r.InstFirst, r.InstEach)
+}
+
+func (r ResultTransaction) paidOrder() (string, string, int) {
+	return r.MerchantOrderNo, r.TradeNo, r.Amt
+}
+
 func (r ResultTransaction) GetMerchantId() string {
 	return r.MerchantID
 }
@@ -187,6 +200,27 @@ func (a Api) CreditCardTransaction(merchant *Merchant, email string,
 	merchantOrderNo, prodDesc, tokenTerm, tokenValue string,
 	amount int,
 	requestedAt xtime.Time,
+) (*RespTransaction, error) {
+	return a.creditCardTransaction(merchant, email, merchantOrderNo, prodDesc, tokenTerm, tokenValue, amount, InstallmentNone, requestedAt)
+}
+
+// 信用卡分期約定付款 (Pn), term 須為商店已啟用的分期期數
+func (a Api) CreditCardInstallmentTransaction(merchant *Merchant, policy InstallmentPolicy, email string,
+	merchantOrderNo, prodDesc, tokenTerm, tokenValue string,
+	amount int, term InstallmentTerm,
+	requestedAt xtime.Time,
+) (*RespTransaction, error) {
+	if err := policy.Validate(amount, term); err != nil {
+		return nil, err
+	}
+
+	return a.creditCardTransaction(merchant, email, merchantOrderNo, prodDesc, tokenTerm, tokenValue, amount, term, requestedAt)
+}
+
+func (a Api) creditCardTransaction(merchant *Merchant, email string,
+	merchantOrderNo, prodDesc, tokenTerm, tokenValue string,
+	amount int, term InstallmentTerm,
+	requestedAt xtime.Time,
 ) (*RespTransaction, error) {
 	data := TransactionPostData{
 		TimeStamp:       strconv.FormatInt(time.Time(requestedAt).Unix(), 10),
@@ -199,7 +233,7 @@ func (a Api) CreditCardTransaction(merchant *Merchant, email string,
 		Amt:             amount,
 		ProdDesc:        prodDesc,
 		PayerEmail:      email,
-		Inst:            "0",
+		Inst:            term.String(),
 		TokenValue:      tokenValue,
 		TokenTerm:       tokenTerm,
 		TokenSwitch:     "on",
@@ -239,6 +273,12 @@ func (a Api) CreditCardTransaction(merchant *Merchant, email string,
 		return nil, fmt.Errorf("failed to decode result: %w", err)
 	}
 	payload.Result = &result
+
+	if payload.IsSuccess() {
+		if err := a.verifyCheckCode(result.VerifyCheckCode(merchant.HashKey, merchant.HashIv)); err != nil {
+			return nil, fmt.Errorf("[transaction] %w", err)
+		}
+	}
 	fmt.Printf("信用卡授權請求結果, transactionId: %s, post url: %s, decrypt post data: %v, encrypt post data: %s, response: %v\n", merchantOrderNo, a.ApiUrlTransaction, data, formData, result)
 	return &payload, nil
 }
@@ -0,0 +1,30 @@
+package newebpay
+
+import "fmt"
+
+var ubnWeights = [8]int{1, 2, 1, 2, 1, 2, 4, 1}
+
+// 營利事業統一編號檢查碼: 各位數乘以權數 1,2,1,2,1,2,4,1 後將乘積的十位數與個位數相加,
+// 總和可被 5 整除即為正確 (財政部 112 年起由 10 改為 5)。
+// 第 7 碼為 7 時, 乘積 28 的和 10 可取 1 或 0, 兩者其一可被 5 整除即為正確。
+func ValidateUBN(ubn string) error {
+	if len(ubn) != 8 {
+		return fmt.Errorf("invalid UBN: %s", ubn)
+	}
+
+	sum := 0
+	for i := 0; i < len(ubn); i++ {
+		c := ubn[i]
+		if c < '0' || c > '9' {
+			return fmt.Errorf("invalid UBN: %s", ubn)
+		}
+
+		p := int(c-'0') * ubnWeights[i]
+		sum += p/10 + p%10
+	}
+
+	if sum%5 == 0 || (ubn[6] == '7' && (sum+1)%5 == 0) {
+		return nil
+	}
+	return fmt.Errorf("invalid UBN checksum: %s", ubn)
+}
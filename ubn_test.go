@@ -0,0 +1,25 @@
+package newebpay
+
+import "testing"
+
+func TestValidateUBN(t *testing.T) {
+	tests := []struct {
+		ubn     string
+		wantErr bool
+	}{
+		{"04595257", false},
+		{"12345675", false}, // 第 7 碼為 7, 總和 39 加 1 後可被 5 整除
+		{"04595258", true},
+		{"12345672", true},
+		{"0459525", true},
+		{"045952570", true},
+		{"0459525A", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateUBN(tt.ubn); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateUBN(%q) error = %v, wantErr %v", tt.ubn, err, tt.wantErr)
+		}
+	}
+}